@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type CatalogEntry struct {
+	URN            string `json:"urn"`
+	CitationScheme string `json:"citationScheme"`
+	GroupName      string `json:"groupName"`
+	WorkTitle      string `json:"workTitle"`
+	VersionLabel   string `json:"versionLabel"`
+	ExemplarLabel  string `json:"exemplarLabel,omitempty"`
+	Online         bool   `json:"online"`
+	Language       string `json:"lang"`
+}
+
+type CatalogResponse struct {
+	RequestUrn []string       `json:"requestUrn"`
+	Status     string         `json:"status"`
+	Service    string         `json:"service"`
+	Message    string         `json:"message,omitempty"`
+	Total      int            `json:"total"`
+	Count      int            `json:"count"`
+	Entries    []CatalogEntry `json:"entries"`
+}
+
+// CatalogFilter selects catalog entries by language, textgroup and online
+// status. Empty fields do not filter; Languages and Groups match any value.
+type CatalogFilter struct {
+	Languages []string
+	Groups    []string
+	Online    *bool
+}
+
+const catalogHeader = "urn#citationScheme#groupName#workTitle#versionLabel#exemplarLabel#online#lang"
+
+// ParseCatalog reads the #!ctscatalog blocks of a CEX source.
+func ParseCatalog(p CTSParams) []CatalogEntry {
+	data, err := getContent(p.Sourcetext)
+	if err != nil {
+		return nil
+	}
+	return parseCatalog(string(data))
+}
+
+func parseCatalog(data string) []CatalogEntry {
+	var response []CatalogEntry
+	for _, block := range cexBlocks(data, "ctscatalog") {
+		for _, line := range cexRecords(block) {
+			if isCTSURN(line[0]) != true {
+				continue
+			}
+			for len(line) < 8 {
+				line = append(line, "")
+			}
+			response = append(response, CatalogEntry{URN: line[0],
+				CitationScheme: line[1],
+				GroupName:      line[2],
+				WorkTitle:      line[3],
+				VersionLabel:   line[4],
+				ExemplarLabel:  line[5],
+				Online:         strings.TrimSpace(strings.ToLower(line[6])) == "true",
+				Language:       line[7]})
+		}
+	}
+	response = append(response, tokenizedCatalog(response, LoadConfiguration("config.json").TokenExemplars)...)
+	response = append(response, orcaCatalog(response, orcaAlignments(data))...)
+	return response
+}
+
+// catalogEntryFor returns the catalog entry for the version or exemplar of urn.
+func catalogEntryFor(catalog []CatalogEntry, urn string) (CatalogEntry, bool) {
+	for i := range catalog {
+		if ctsStem(catalog[i].URN) == ctsStem(urn) {
+			return catalog[i], true
+		}
+	}
+	return CatalogEntry{}, false
+}
+
+// catalogFilterFor reads the lang, group and online query parameters; lang and
+// group take comma-separated lists, group either textgroup URNs or IDs.
+func catalogFilterFor(r *http.Request) (CatalogFilter, error) {
+	var filter CatalogFilter
+	query := r.URL.Query()
+	for _, lang := range strings.Split(query.Get("lang"), ",") {
+		if strings.TrimSpace(lang) != "" {
+			filter.Languages = append(filter.Languages, strings.TrimSpace(lang))
+		}
+	}
+	for _, group := range strings.Split(query.Get("group"), ",") {
+		if strings.TrimSpace(group) != "" {
+			filter.Groups = append(filter.Groups, strings.TrimSpace(group))
+		}
+	}
+	if query.Get("online") != "" {
+		online, err := strconv.ParseBool(query.Get("online"))
+		if err != nil {
+			return filter, fmt.Errorf("Invalid online value %v.", query.Get("online"))
+		}
+		filter.Online = &online
+	}
+	return filter, nil
+}
+
+// Matches reports whether the entry passes every filter.
+func (f CatalogFilter) Matches(entry CatalogEntry) bool {
+	if len(f.Languages) > 0 && !contains(f.Languages, entry.Language) {
+		return false
+	}
+	if f.Online != nil && *f.Online != entry.Online {
+		return false
+	}
+	if len(f.Groups) == 0 {
+		return true
+	}
+	for _, group := range f.Groups {
+		switch {
+		case isCTSURN(group):
+			if urnContains(ctsStem(group)+":", entry.URN) {
+				return true
+			}
+		case strings.Split(strings.Split(entry.URN, ":")[3], ".")[0] == group:
+			return true
+		}
+	}
+	return false
+}
+
+// CEX returns the entry as a #!ctscatalog row.
+func (c CatalogEntry) CEX() string {
+	online := "false"
+	if c.Online {
+		online = "true"
+	}
+	return strings.Join([]string{c.URN, c.CitationScheme, c.GroupName, c.WorkTitle, c.VersionLabel, c.ExemplarLabel, online, c.Language}, "#")
+}
+
+func ReturnCatalogVersion(w http.ResponseWriter, r *http.Request) {
+	var result VersionResponse
+	result = VersionResponse{
+		Status:  "Success",
+		Service: "/textcatalog/version",
+		Version: "1.0.0"}
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
+
+func ReturnCatalog(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	filter, err := catalogFilterFor(r)
+	var result CatalogResponse
+	switch {
+	case requestUrn != "" && isCTSURN(requestUrn) != true:
+		message := requestUrn + " is not valid CTS."
+		result = CatalogResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	case err != nil:
+		result = CatalogResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+	default:
+		total := 0
+		entries := []CatalogEntry{}
+		for _, entry := range ParseCatalog(CTSParams{Sourcetext: sourcetext}) {
+			if requestUrn == "" || urnContains(ctsStem(requestUrn)+":", entry.URN) {
+				total++
+				if filter.Matches(entry) {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		switch {
+		case total == 0 && requestUrn != "":
+			message := "No results for " + requestUrn
+			result = CatalogResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = CatalogResponse{RequestUrn: []string{requestUrn}, Status: "Success", Total: total, Count: len(entries), Entries: entries}
+		}
+	}
+	if requestUrn == "" {
+		result.RequestUrn = []string{}
+	}
+	result.Service = "/textcatalog"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCatalogFilterMatches(t *testing.T) {
+	yes, no := true, false
+	greek := CatalogEntry{URN: "urn:cts:greekLit:tlg0012.tlg001.msA:", Language: "grc", Online: true}
+	latin := CatalogEntry{URN: "urn:cts:latinLit:phi0690.phi003.ed:", Language: "lat", Online: false}
+	tests := []struct {
+		filter CatalogFilter
+		entry  CatalogEntry
+		want   bool
+	}{
+		{CatalogFilter{}, greek, true},
+		{CatalogFilter{Languages: []string{"grc"}}, greek, true},
+		{CatalogFilter{Languages: []string{"grc"}}, latin, false},
+		{CatalogFilter{Languages: []string{"grc", "lat"}}, latin, true},
+		{CatalogFilter{Online: &yes}, greek, true},
+		{CatalogFilter{Online: &yes}, latin, false},
+		{CatalogFilter{Online: &no}, latin, true},
+		{CatalogFilter{Groups: []string{"tlg0012"}}, greek, true},
+		{CatalogFilter{Groups: []string{"tlg0012"}}, latin, false},
+		{CatalogFilter{Groups: []string{"urn:cts:latinLit:phi0690:"}}, latin, true},
+		{CatalogFilter{Groups: []string{"urn:cts:greekLit:phi0690:"}}, latin, false},
+		{CatalogFilter{Groups: []string{"tlg0012", "phi0690"}, Languages: []string{"lat"}}, latin, true},
+		{CatalogFilter{Groups: []string{"tlg0012"}, Online: &no}, greek, false},
+	}
+	for _, test := range tests {
+		if got := test.filter.Matches(test.entry); got != test.want {
+			t.Errorf("%+v.Matches(%s) = %v; want %v", test.filter, test.entry.URN, got, test.want)
+		}
+	}
+}
+
+func TestCatalogFilterFor(t *testing.T) {
+	yes := true
+	tests := []struct {
+		query string
+		want  CatalogFilter
+		ok    bool
+	}{
+		{"", CatalogFilter{}, true},
+		{"?lang=grc,%20lat,&group=tlg0012", CatalogFilter{Languages: []string{"grc", "lat"}, Groups: []string{"tlg0012"}}, true},
+		{"?online=true", CatalogFilter{Online: &yes}, true},
+		{"?online=maybe", CatalogFilter{}, false},
+	}
+	for _, test := range tests {
+		got, err := catalogFilterFor(httptest.NewRequest("GET", "/textcatalog"+test.query, nil))
+		if (err == nil) != test.ok || (test.ok && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("catalogFilterFor(%q) = %+v, %v; want %+v", test.query, got, err, test.want)
+		}
+	}
+}
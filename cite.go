@@ -0,0 +1,112 @@
+package main
+
+import "strings"
+
+// LibrarySummary describes a CEX library: its #!citelibrary metadata and
+// how much data it holds for each service.
+type LibrarySummary struct {
+	Name           string `json:"name,omitempty"`
+	URN            string `json:"urn,omitempty"`
+	License        string `json:"license,omitempty"`
+	Texts          int    `json:"texts"`
+	Nodes          int    `json:"nodes"`
+	Collections    int    `json:"collections"`
+	Objects        int    `json:"objects"`
+	Relations      int    `json:"relations"`
+	DataModels     int    `json:"datamodels"`
+	DSERecords     int    `json:"dseRecords"`
+	ORCAAlignments int    `json:"orcaAlignments"`
+}
+
+// LibraryReport summarises a CEX source and reports the version of every
+// service that has data in it. Services without data are left empty.
+func LibraryReport(str string) (Versions, LibrarySummary) {
+	var versions Versions
+	var summary LibrarySummary
+	for _, block := range cexBlocks(str, "citelibrary") {
+		for _, line := range cexRecords(block) {
+			if len(line) < 2 {
+				continue
+			}
+			switch strings.TrimSpace(line[0]) {
+			case "name":
+				summary.Name = line[1]
+			case "urn":
+				summary.URN = line[1]
+			case "license":
+				summary.License = line[1]
+			}
+		}
+	}
+	var texts []string
+	for _, block := range cexBlocks(str, "ctsdata") {
+		for _, line := range cexRecords(block) {
+			if isCTSURN(line[0]) != true {
+				continue
+			}
+			summary.Nodes++
+			if !contains(texts, ctsStem(line[0])) {
+				texts = append(texts, ctsStem(line[0]))
+			}
+		}
+	}
+	summary.Texts = len(texts)
+	if summary.Nodes > 0 {
+		versions.Texts = "1.1.0"
+	}
+	if len(cexBlocks(str, "ctscatalog")) > 0 {
+		versions.Textcatalog = "1.0.0"
+	}
+	library := parseCollections(str)
+	summary.Collections = len(library.Collections)
+	summary.Objects = len(library.Objects)
+	if summary.Collections > 0 {
+		versions.Citecatalog = "1.0.0"
+	}
+	if summary.Objects > 0 {
+		versions.Citedata = "1.0.0"
+	}
+	summary.Relations = len(parseRelations(str))
+	if summary.Relations > 0 {
+		versions.Citerelations = "1.0.0"
+	}
+	models := parseDataModels(str)
+	summary.DataModels = len(models)
+	if summary.DataModels > 0 {
+		versions.Citeextensions = "1.0.0"
+	}
+	for _, collection := range modelCollections(models, dseModel) {
+		summary.DSERecords += len(collectionObjects(library, collection))
+	}
+	if summary.DSERecords > 0 {
+		versions.DSE = "1.0.0"
+	}
+	summary.ORCAAlignments = len(orcaAlignments(str))
+	if summary.ORCAAlignments > 0 {
+		versions.ORCA = "1.0.0"
+	}
+	return versions, summary
+}
+
+// availableServices lists the endpoints that have data, prefixed with the
+// requested CEX.
+func availableServices(versions Versions, prefix string) []string {
+	var result []string
+	add := func(version string, services ...string) {
+		if version == "" {
+			return
+		}
+		for _, service := range services {
+			result = append(result, prefix+service)
+		}
+	}
+	add(versions.Texts, "/texts", "/api/cts", "/dts")
+	add(versions.Textcatalog, "/textcatalog")
+	add(versions.Citecatalog, "/collections")
+	add(versions.Citedata, "/objects")
+	add(versions.Citerelations, "/relations", "/commentary")
+	add(versions.Citeextensions, "/datamodels")
+	add(versions.DSE, "/dse", "/iiif/manifest")
+	add(versions.ORCA, "/orca")
+	return result
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Cite2Urn is a parsed urn:cite2:namespace:collection[.version[.property]]:[selector]
+// URN. The object selector is either a single object or a range; each object
+// may carry an extension after "@", such as an image region of interest.
+type Cite2Urn struct {
+	Namespace      string `json:"namespace"`
+	Collection     string `json:"collection"`
+	Version        string `json:"version,omitempty"`
+	Property       string `json:"property,omitempty"`
+	Object         string `json:"object,omitempty"`
+	Extension      string `json:"extension,omitempty"`
+	RangeBegin     string `json:"rangeBegin,omitempty"`
+	BeginExtension string `json:"rangeBeginExtension,omitempty"`
+	RangeEnd       string `json:"rangeEnd,omitempty"`
+	EndExtension   string `json:"rangeEndExtension,omitempty"`
+}
+
+type Cite2Response struct {
+	RequestUrn []string  `json:"requestUrn"`
+	Status     string    `json:"status"`
+	Service    string    `json:"service"`
+	Message    string    `json:"message,omitempty"`
+	URN        *Cite2Urn `json:"urn,omitempty"`
+	Collection string    `json:"collectionUrn,omitempty"`
+}
+
+var cite2Identifier = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+var cite2Object = regexp.MustCompile(`^[^:@\-]+$`)
+
+// splitExtension separates an object identifier from its "@" extension.
+func splitExtension(s string) (string, string, error) {
+	parts := strings.Split(s, "@")
+	switch {
+	case len(parts) > 2:
+		return "", "", errors.New("Object " + s + " has more than one extension.")
+	case !cite2Object.MatchString(parts[0]):
+		return "", "", errors.New("Invalid object identifier " + parts[0] + ".")
+	case len(parts) == 2 && parts[1] == "":
+		return "", "", errors.New("Empty extension on object " + parts[0] + ".")
+	case len(parts) == 2:
+		return parts[0], parts[1], nil
+	default:
+		return parts[0], "", nil
+	}
+}
+
+// ParseCite2Urn parses and validates a CITE2 URN.
+func ParseCite2Urn(s string) (Cite2Urn, error) {
+	var result Cite2Urn
+	parts := strings.Split(s, ":")
+	switch {
+	case len(parts) < 2 || parts[0] != "urn" || parts[1] != "cite2":
+		return result, errors.New(s + " does not begin with urn:cite2.")
+	case len(parts) != 5:
+		return result, errors.New(s + " must have five colon-delimited components.")
+	case !cite2Identifier.MatchString(parts[2]):
+		return result, errors.New("Invalid namespace " + parts[2] + " in " + s + ".")
+	}
+	result.Namespace = parts[2]
+	work := strings.Split(parts[3], ".")
+	if len(work) > 3 {
+		return result, errors.New("Collection component " + parts[3] + " has more than collection.version.property.")
+	}
+	for _, component := range work {
+		if !cite2Identifier.MatchString(component) {
+			return result, errors.New("Invalid collection component " + parts[3] + " in " + s + ".")
+		}
+	}
+	result.Collection = work[0]
+	if len(work) > 1 {
+		result.Version = work[1]
+	}
+	if len(work) > 2 {
+		result.Property = work[2]
+	}
+	selector := parts[4]
+	var err error
+	switch {
+	case selector == "":
+	case strings.Count(selector, "-") > 1:
+		return result, errors.New("Range " + selector + " has more than one \"-\".")
+	case strings.Contains(selector, "-"):
+		ends := strings.Split(selector, "-")
+		if ends[0] == "" || ends[1] == "" {
+			return result, errors.New("Range " + selector + " needs a beginning and an end.")
+		}
+		if result.RangeBegin, result.BeginExtension, err = splitExtension(ends[0]); err != nil {
+			return result, err
+		}
+		if result.RangeEnd, result.EndExtension, err = splitExtension(ends[1]); err != nil {
+			return result, err
+		}
+	default:
+		if result.Object, result.Extension, err = splitExtension(selector); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (u Cite2Urn) IsRange() bool {
+	return u.RangeBegin != ""
+}
+
+// IsCollection reports whether the URN names a collection without objects.
+func (u Cite2Urn) IsCollection() bool {
+	return u.Object == "" && u.RangeBegin == ""
+}
+
+// CollectionURN returns urn:cite2:namespace:collection.version: without
+// property, selector or extensions.
+func (u Cite2Urn) CollectionURN() string {
+	work := u.Collection
+	if u.Version != "" {
+		work = work + "." + u.Version
+	}
+	return "urn:cite2:" + u.Namespace + ":" + work + ":"
+}
+
+// PropertyURN returns the URN of the property the URN extends to, if any.
+func (u Cite2Urn) PropertyURN() string {
+	if u.Property == "" {
+		return ""
+	}
+	return strings.TrimSuffix(u.CollectionURN(), ":") + "." + u.Property + ":"
+}
+
+// ObjectURN returns the URN of a single object without property or extension.
+func (u Cite2Urn) ObjectURN() string {
+	return u.CollectionURN() + u.Object
+}
+
+func isCite2URN(s string) bool {
+	_, err := ParseCite2Urn(s)
+	return err == nil
+}
+
+// cite2Collection returns the collection URN (urn:cite2:ns:coll.version:) of
+// a CITE2 object or property URN.
+func cite2Collection(s string) string {
+	urn, err := ParseCite2Urn(s)
+	if err != nil {
+		return s
+	}
+	return urn.CollectionURN()
+}
+
+func ReturnCite2Urn(w http.ResponseWriter, r *http.Request) {
+	requestUrn := mux.Vars(r)["URN"]
+	var result Cite2Response
+	urn, err := ParseCite2Urn(requestUrn)
+	switch {
+	case err != nil:
+		result = Cite2Response{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+	default:
+		result = Cite2Response{RequestUrn: []string{requestUrn}, Status: "Success", URN: &urn, Collection: urn.CollectionURN()}
+	}
+	result.Service = "/cite2"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
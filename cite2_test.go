@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestParseCite2Urn(t *testing.T) {
+	tests := []struct {
+		urn  string
+		want Cite2Urn
+		ok   bool
+	}{
+		{"urn:cite2:hmt:msA:", Cite2Urn{Namespace: "hmt", Collection: "msA"}, true},
+		{"urn:cite2:hmt:msA.v1:12r", Cite2Urn{Namespace: "hmt", Collection: "msA", Version: "v1", Object: "12r"}, true},
+		{"urn:cite2:hmt:msA.v1.rv:12r", Cite2Urn{Namespace: "hmt", Collection: "msA", Version: "v1", Property: "rv", Object: "12r"}, true},
+		{"urn:cite2:hmt:vaimg.2017a:VA012RN_0013@0.1,0.2,0.3,0.4", Cite2Urn{Namespace: "hmt", Collection: "vaimg", Version: "2017a", Object: "VA012RN_0013", Extension: "0.1,0.2,0.3,0.4"}, true},
+		{"urn:cite2:hmt:msA.v1:12r-13v", Cite2Urn{Namespace: "hmt", Collection: "msA", Version: "v1", RangeBegin: "12r", RangeEnd: "13v"}, true},
+		{"urn:cite2:hmt:img.v1:a@x-b@y", Cite2Urn{Namespace: "hmt", Collection: "img", Version: "v1", RangeBegin: "a", BeginExtension: "x", RangeEnd: "b", EndExtension: "y"}, true},
+		{"urn:cts:hmt:msA.v1:12r", Cite2Urn{}, false},
+		{"urn:cite2:hmt:msA.v1", Cite2Urn{}, false},
+		{"urn:cite2:hmt:msA.v1:12r:x", Cite2Urn{}, false},
+		{"urn:cite2:h m t:msA.v1:12r", Cite2Urn{}, false},
+		{"urn:cite2:hmt:msA.v1.rv.x:12r", Cite2Urn{}, false},
+		{"urn:cite2:hmt:msA..v1:12r", Cite2Urn{}, false},
+		{"urn:cite2:hmt:msA.v1:12r-13v-14r", Cite2Urn{}, false},
+		{"urn:cite2:hmt:msA.v1:12r-", Cite2Urn{}, false},
+		{"urn:cite2:hmt:msA.v1:12r@a@b", Cite2Urn{}, false},
+		{"urn:cite2:hmt:msA.v1:12r@", Cite2Urn{}, false},
+	}
+	for _, test := range tests {
+		got, err := ParseCite2Urn(test.urn)
+		if (err == nil) != test.ok || (test.ok && got != test.want) {
+			t.Errorf("ParseCite2Urn(%q) = %+v, %v; want %+v", test.urn, got, err, test.want)
+		}
+	}
+}
+
+func TestCite2UrnComponents(t *testing.T) {
+	urn, err := ParseCite2Urn("urn:cite2:hmt:vaimg.2017a.rights:VA012RN_0013@0.1,0.2,0.3,0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, got, want string
+	}{
+		{"CollectionURN", urn.CollectionURN(), "urn:cite2:hmt:vaimg.2017a:"},
+		{"PropertyURN", urn.PropertyURN(), "urn:cite2:hmt:vaimg.2017a.rights:"},
+		{"ObjectURN", urn.ObjectURN(), "urn:cite2:hmt:vaimg.2017a:VA012RN_0013"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s() = %q; want %q", test.name, test.got, test.want)
+		}
+	}
+	if urn.IsRange() || urn.IsCollection() {
+		t.Errorf("%+v reported as range or collection", urn)
+	}
+}
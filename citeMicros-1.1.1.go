@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -29,7 +31,7 @@ type Node struct {
 }
 
 type Versions struct {
-	Texts          string `json:"texts"`
+	Texts          string `json:"texts,omitempty"`
 	Textcatalog    string `json:"textcatalog,omitempty"`
 	Citedata       string `json:"citedata,omitempty"`
 	Citecatalog    string `json:"citecatalog,omitempty"`
@@ -40,9 +42,12 @@ type Versions struct {
 }
 
 type CITEResponse struct {
-	Status   string   `json:"status"`
-	Service  string   `json:"service"`
-	Versions Versions `json:"versions"`
+	Status   string          `json:"status"`
+	Service  string          `json:"service"`
+	Message  string          `json:"message,omitempty"`
+	Versions Versions        `json:"versions"`
+	Library  *LibrarySummary `json:"library,omitempty"`
+	Services []string        `json:"services,omitempty"`
 }
 
 type VersionResponse struct {
@@ -73,6 +78,8 @@ type Work struct {
 	URN     []string
 	Text    []string
 	Index   []int
+	// Derived lists the stems of the exemplars derived from #!ctsdata.
+	Derived []string
 }
 
 type Collection struct {
@@ -84,10 +91,16 @@ type CTSParams struct {
 }
 
 type ServerConfig struct {
-	Host       string `json:"host"`
-	Port       string `json:"port"`
-	Source     string `json:"cex_source"`
-	TestSource string `json:"test_cex_source"`
+	Host           string                 `json:"host"`
+	Port           string                 `json:"port"`
+	Source         string                 `json:"cex_source"`
+	TestSource     string                 `json:"test_cex_source"`
+	TokenExemplars map[string]string      `json:"tokenized_exemplars"`
+	IIIFImages     map[string]string      `json:"iiif_images"`
+	IIIFServices   map[string]IIIFService `json:"iiif_image_services"`
+	CanvasWidth    int                    `json:"iiif_canvas_width"`
+	CanvasHeight   int                    `json:"iiif_canvas_height"`
+	ImageDirectory string                 `json:"iiif_image_directory"`
 }
 
 func splitCTS(s string) CTSURN {
@@ -96,6 +109,110 @@ func splitCTS(s string) CTSURN {
 	return result
 }
 
+// ctsStem returns the first four components (urn:cts:namespace:work) of a CTS URN.
+func ctsStem(s string) string {
+	parts := strings.Split(s, ":")
+	if len(parts) < 4 {
+		return s
+	}
+	return strings.Join(parts[0:4], ":")
+}
+
+// ctsReference returns the passage component of a CTS URN without subreference.
+func ctsReference(s string) string {
+	parts := strings.Split(s, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return strings.Split(parts[4], "@")[0]
+}
+
+// urnContains reports whether the (non-range) request URN contains node, either
+// as the same passage or as a passage further down the citation hierarchy.
+// Work-level requests contain every version and exemplar of the work.
+func urnContains(request, node string) bool {
+	rp := strings.Split(request, ":")
+	np := strings.Split(node, ":")
+	if len(rp) < 4 || len(np) < 4 || rp[2] != np[2] {
+		return false
+	}
+	rw := strings.Split(rp[3], ".")
+	nw := strings.Split(np[3], ".")
+	if len(rw) > len(nw) {
+		return false
+	}
+	for i := range rw {
+		if rw[i] != nw[i] {
+			return false
+		}
+	}
+	ref := ctsReference(request)
+	if ref == "" {
+		return true
+	}
+	nodeRef := ctsReference(node)
+	return nodeRef == ref || strings.HasPrefix(nodeRef, ref+".")
+}
+
+// scopeIndices returns the positions of all urns within the scope of requestUrn.
+// Ranges are resolved separately for every version the request covers.
+func scopeIndices(urns []string, requestUrn string) []int {
+	var result []int
+	if !isRange(requestUrn) {
+		for i := range urns {
+			if urnContains(requestUrn, urns[i]) {
+				result = append(result, i)
+			}
+		}
+		return result
+	}
+	ctsurn := splitCTS(requestUrn)
+	ctsrange := strings.Split(ctsurn.Reference, "-")
+	startURN := ctsurn.Stem + ":" + ctsrange[0]
+	endURN := ctsurn.Stem + ":" + ctsrange[1]
+	lastindex := map[string]int{}
+	for i := range urns {
+		if urnContains(endURN, urns[i]) {
+			lastindex[ctsStem(urns[i])] = i
+		}
+	}
+	started := map[string]bool{}
+	for i := range urns {
+		stem := ctsStem(urns[i])
+		if !started[stem] && urnContains(startURN, urns[i]) {
+			started[stem] = true
+		}
+		if !started[stem] {
+			continue
+		}
+		if endindex, ok := lastindex[stem]; ok && i > endindex {
+			continue
+		}
+		result = append(result, i)
+	}
+	return result
+}
+
+// derivedNode reports whether node i of work belongs to a tokenized or
+// analytical exemplar rather than to #!ctsdata.
+func derivedNode(work Work, i int) bool {
+	return contains(work.Derived, ctsStem(work.URN[i]))
+}
+
+// explicitScopeIndices is scopeIndices without the derived exemplars the
+// request does not name, so that a version and its derived exemplars are not
+// mixed.
+func explicitScopeIndices(work Work, requestUrn string) []int {
+	var result []int
+	for _, i := range scopeIndices(work.URN, requestUrn) {
+		if derivedNode(work, i) && ctsStem(work.URN[i]) != ctsStem(requestUrn) {
+			continue
+		}
+		result = append(result, i)
+	}
+	return result
+}
+
 func LoadConfiguration(file string) ServerConfig {
 	var config ServerConfig
 	configFile, err := os.Open(file)
@@ -108,6 +225,18 @@ func LoadConfiguration(file string) ServerConfig {
 	return config
 }
 
+// requestSource returns the CEX file a request refers to via its {CEX} prefix.
+func requestSource(r *http.Request) string {
+	confvar := LoadConfiguration("config.json")
+	requestCEX := mux.Vars(r)["CEX"]
+	switch {
+	case requestCEX != "":
+		return confvar.Source + requestCEX + ".cex"
+	default:
+		return confvar.TestSource
+	}
+}
+
 func contains(s []string, e string) bool {
 	for _, a := range s {
 		if a == e {
@@ -206,6 +335,14 @@ func removeDuplicatesUnordered(elements []string) []string {
 }
 
 func main() {
+	validate := flag.String("validate", "", "cross-check catalog, data and citedata types of a CEX file (name under cex_source or URL) and exit")
+	flag.Parse()
+	if *validate != "" {
+		if !ValidateSource(validationSource(*validate)) {
+			os.Exit(1)
+		}
+		return
+	}
 	confvar := LoadConfiguration("./config.json")
 	serverIP := confvar.Port
 	router := mux.NewRouter().StrictSlash(true)
@@ -217,13 +354,83 @@ func main() {
 	router.HandleFunc("/texts/previous/{URN}", ReturnPrev)
 	router.HandleFunc("/texts/next/{URN}", ReturnNext)
 	router.HandleFunc("/texts/urns/{URN}", ReturnReff)
+	router.HandleFunc("/texts/stats", ReturnStats)
+	router.HandleFunc("/texts/stats/{URN}", ReturnStats)
+	router.HandleFunc("/texts/words/{URN}", ReturnWords)
 	router.HandleFunc("/texts/{URN}", ReturnPassage)
+	router.HandleFunc("/textcatalog", ReturnCatalog)
+	router.HandleFunc("/textcatalog/version", ReturnCatalogVersion)
+	router.HandleFunc("/textcatalog/validate", ReturnValidation)
+	router.HandleFunc("/textcatalog/{URN}", ReturnCatalog)
+	router.HandleFunc("/collections", ReturnCollections)
+	router.HandleFunc("/collections/validate", ReturnCollectionValidation)
+	router.HandleFunc("/collections/{URN}", ReturnCollections)
+	router.HandleFunc("/objects/find/{URN}", ReturnFindObjects)
+	router.HandleFunc("/objects/first/{URN}", ReturnFirstObject)
+	router.HandleFunc("/objects/last/{URN}", ReturnLastObject)
+	router.HandleFunc("/objects/prev/{URN}", ReturnPrevObject)
+	router.HandleFunc("/objects/previous/{URN}", ReturnPrevObject)
+	router.HandleFunc("/objects/next/{URN}", ReturnNextObject)
+	router.HandleFunc("/objects/{URN}", ReturnObjects)
+	router.HandleFunc("/relations/graph/{URN}", ReturnRelationGraph)
+	router.HandleFunc("/relations/{URN}", ReturnRelations)
+	router.HandleFunc("/dse/recordsForText/{URN}", ReturnDSEForText)
+	router.HandleFunc("/dse/recordsForImage/{URN}", ReturnDSEForImage)
+	router.HandleFunc("/dse/recordsForSurface/{URN}", ReturnDSEForSurface)
+	router.HandleFunc("/image/{URN}", ReturnImage)
+	router.HandleFunc("/iiif/manifest/{URN}", ReturnManifest)
+	router.HandleFunc("/iiif/image/{URN}", ReturnImageBase)
+	router.HandleFunc("/iiif/image/{URN}/info.json", ReturnImageInfo)
+	router.HandleFunc("/iiif/image/{URN}/{region}/{size}/{rotation}/{quality}", ReturnImageRequest)
+	router.HandleFunc("/orca/{URN}", ReturnORCA)
+	router.HandleFunc("/commentary/{URN}", ReturnCommentary)
+	router.HandleFunc("/datamodels", ReturnDataModels)
+	router.HandleFunc("/cite2/{URN}", ReturnCite2Urn)
+	router.HandleFunc("/api/cts", ReturnCTS)
+	router.HandleFunc("/dts", ReturnDTSEntryPoint)
+	router.HandleFunc("/dts/collections", ReturnDTSCollections)
+	router.HandleFunc("/dts/navigation", ReturnDTSNavigation)
+	router.HandleFunc("/dts/document", ReturnDTSDocument)
+	// mux takes the first matching route, so every fixed path above must be
+	// registered before the /{CEX}/... routes below.
+	router.HandleFunc("/{CEX}/cite", ReturnCiteVersion)
+	router.HandleFunc("/{CEX}/textcatalog", ReturnCatalog)
+	router.HandleFunc("/{CEX}/textcatalog/version", ReturnCatalogVersion)
+	router.HandleFunc("/{CEX}/textcatalog/validate", ReturnValidation)
+	router.HandleFunc("/{CEX}/textcatalog/{URN}", ReturnCatalog)
+	router.HandleFunc("/{CEX}/collections", ReturnCollections)
+	router.HandleFunc("/{CEX}/collections/validate", ReturnCollectionValidation)
+	router.HandleFunc("/{CEX}/collections/{URN}", ReturnCollections)
+	router.HandleFunc("/{CEX}/objects/find/{URN}", ReturnFindObjects)
+	router.HandleFunc("/{CEX}/objects/first/{URN}", ReturnFirstObject)
+	router.HandleFunc("/{CEX}/objects/last/{URN}", ReturnLastObject)
+	router.HandleFunc("/{CEX}/objects/prev/{URN}", ReturnPrevObject)
+	router.HandleFunc("/{CEX}/objects/previous/{URN}", ReturnPrevObject)
+	router.HandleFunc("/{CEX}/objects/next/{URN}", ReturnNextObject)
+	router.HandleFunc("/{CEX}/objects/{URN}", ReturnObjects)
+	router.HandleFunc("/{CEX}/dse/recordsForText/{URN}", ReturnDSEForText)
+	router.HandleFunc("/{CEX}/dse/recordsForImage/{URN}", ReturnDSEForImage)
+	router.HandleFunc("/{CEX}/dse/recordsForSurface/{URN}", ReturnDSEForSurface)
+	router.HandleFunc("/{CEX}/iiif/manifest/{URN}", ReturnManifest)
+	router.HandleFunc("/{CEX}/orca/{URN}", ReturnORCA)
+	router.HandleFunc("/{CEX}/commentary/{URN}", ReturnCommentary)
+	router.HandleFunc("/{CEX}/datamodels", ReturnDataModels)
+	router.HandleFunc("/{CEX}/relations/graph/{URN}", ReturnRelationGraph)
+	router.HandleFunc("/{CEX}/relations/{URN}", ReturnRelations)
+	router.HandleFunc("/{CEX}/api/cts", ReturnCTS)
+	router.HandleFunc("/{CEX}/dts", ReturnDTSEntryPoint)
+	router.HandleFunc("/{CEX}/dts/collections", ReturnDTSCollections)
+	router.HandleFunc("/{CEX}/dts/navigation", ReturnDTSNavigation)
+	router.HandleFunc("/{CEX}/dts/document", ReturnDTSDocument)
 	router.HandleFunc("/{CEX}/texts/", ReturnWorkURNS)
 	router.HandleFunc("/{CEX}/texts/first/{URN}", ReturnFirst)
 	router.HandleFunc("/{CEX}/texts/last/{URN}", ReturnLast)
 	router.HandleFunc("/{CEX}/texts/previous/{URN}", ReturnPrev)
 	router.HandleFunc("/{CEX}/texts/next/{URN}", ReturnNext)
 	router.HandleFunc("/{CEX}/texts/urns/{URN}", ReturnReff)
+	router.HandleFunc("/{CEX}/texts/stats", ReturnStats)
+	router.HandleFunc("/{CEX}/texts/stats/{URN}", ReturnStats)
+	router.HandleFunc("/{CEX}/texts/words/{URN}", ReturnWords)
 	router.HandleFunc("/{CEX}/texts/{URN}", ReturnPassage)
 	router.HandleFunc("/", ReturnCiteVersion)
 	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type"})
@@ -252,6 +459,42 @@ func getContent(url string) ([]byte, error) {
 	return data, nil
 }
 
+// cexBlocks returns the contents of every block with the given label
+// (e.g. "ctscatalog") with comment lines removed.
+func cexBlocks(data string, label string) []string {
+	var result []string
+	header := regexp.MustCompile("(?m)^#!" + label + "[ \t\r]*$")
+	re := regexp.MustCompile("(?m)[\r\n]*^//.*$")
+	for _, loc := range header.FindAllStringIndex(data, -1) {
+		block := data[loc[1]:]
+		if end := strings.Index(block, "\n#!"); end >= 0 {
+			block = block[:end]
+		}
+		result = append(result, re.ReplaceAllString(block, ""))
+	}
+	return result
+}
+
+// cexRecords splits a CEX block into its #-delimited records.
+func cexRecords(block string) [][]string {
+	reader := csv.NewReader(strings.NewReader(block))
+	reader.Comma = '#'
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	var result [][]string
+	for {
+		line, error := reader.Read()
+		if error == io.EOF {
+			break
+		} else if error != nil {
+			log.Println(error)
+			break
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
 func ReturnWorkURNS(w http.ResponseWriter, r *http.Request) {
 	confvar := LoadConfiguration("config.json")
 	vars := mux.Vars(r)
@@ -285,30 +528,14 @@ func ParseURNS(p CTSParams) URNResponse {
 	}
 
 	str := string(data)
-	// Remove comments
-	str = strings.Split(str, "#!ctsdata")[1]
-	str = strings.Split(str, "#!")[0]
-	re := regexp.MustCompile("(?m)[\r\n]*^//.*$")
-	str = re.ReplaceAllString(str, "")
-
-	reader := csv.NewReader(strings.NewReader(str))
-	reader.Comma = '#'
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = 2
-
-	var response URNResponse
-
-	for {
-		line, error := reader.Read()
-		if error == io.EOF {
-			break
-		} else if error != nil {
-			log.Fatal(error)
-		}
-		response.URN = append(response.URN, line[0])
+	if !strings.Contains(str, "#!ctsdata") {
+		return URNResponse{Status: "Exception", Message: "No #!ctsdata in source."}
+	}
+	work, err := parseWork(str)
+	if err != nil {
+		log.Println(input_file + ": " + err.Error())
 	}
-	response.Status = "Success"
-	return response
+	return URNResponse{Status: "Success", URN: work.URN}
 }
 
 func ParseWork(p CTSParams) Work {
@@ -317,8 +544,31 @@ func ParseWork(p CTSParams) Work {
 	if err != nil {
 		return Work{}
 	}
+	work, err := parseWork(string(data))
+	if err != nil {
+		log.Println(input_file + ": " + err.Error())
+	}
+	return work
+}
 
-	str := string(data)
+// MalformedRowsError lists the rows of a CEX block that could not be read.
+type MalformedRowsError struct {
+	Block string
+	Rows  []string
+}
+
+func (e MalformedRowsError) Error() string {
+	return "Malformed #!" + e.Block + " rows: " + strings.Join(e.Rows, "; ")
+}
+
+// parseWork reads the nodes of #!ctsdata and derives the configured
+// exemplars. Rows without exactly one URN and one text are skipped and
+// returned in a MalformedRowsError.
+func parseWork(data string) (Work, error) {
+	str := data
+	if !strings.Contains(str, "#!ctsdata") {
+		return Work{}, nil
+	}
 	str = strings.Split(str, "#!ctsdata")[1]
 	str = strings.Split(str, "#!")[0]
 	re := regexp.MustCompile("(?m)[\r\n]*^//.*$")
@@ -330,25 +580,54 @@ func ParseWork(p CTSParams) Work {
 	reader.FieldsPerRecord = 2
 
 	var response Work
+	malformed := MalformedRowsError{Block: "ctsdata"}
 
 	for {
 		line, error := reader.Read()
 		if error == io.EOF {
 			break
+		} else if errors.Is(error, csv.ErrFieldCount) {
+			malformed.Rows = append(malformed.Rows, strings.Join(line, "#"))
+			continue
 		} else if error != nil {
-			log.Fatal(error)
+			malformed.Rows = append(malformed.Rows, error.Error())
+			break
 		}
 		response.URN = append(response.URN, line[0])
 		response.Text = append(response.Text, line[1])
 	}
-	return response
+	exemplar := tokenizedExemplars(response, LoadConfiguration("config.json").TokenExemplars)
+	analytical := orcaExemplars(orcaAlignments(data), response)
+	for _, urn := range append(exemplar.URN, analytical.URN...) {
+		if !contains(response.Derived, ctsStem(urn)) {
+			response.Derived = append(response.Derived, ctsStem(urn))
+		}
+	}
+	response.URN = append(response.URN, exemplar.URN...)
+	response.Text = append(response.Text, exemplar.Text...)
+	response.URN = append(response.URN, analytical.URN...)
+	response.Text = append(response.Text, analytical.Text...)
+	if len(malformed.Rows) > 0 {
+		return response, malformed
+	}
+	return response, nil
 }
 
 func ReturnCiteVersion(w http.ResponseWriter, r *http.Request) {
 	var result CITEResponse
-	result = CITEResponse{Status: "Success",
-		Service:  "/cite",
-		Versions: Versions{Texts: "1.1.0", Textcatalog: ""}}
+	data, err := getContent(requestSource(r))
+	switch {
+	case err != nil:
+		result = CITEResponse{Status: "Exception", Message: "Couldn't open connection."}
+	default:
+		versions, summary := LibraryReport(string(data))
+		prefix := ""
+		if requestCEX := mux.Vars(r)["CEX"]; requestCEX != "" {
+			prefix = "/" + requestCEX
+		}
+		result = CITEResponse{Status: "Success", Versions: versions, Library: &summary, Services: availableServices(versions, prefix)}
+	}
+	result.Service = "/cite"
 	resultJSON, _ := json.Marshal(result)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	fmt.Fprintln(w, string(resultJSON))
@@ -969,9 +1248,7 @@ func ReturnPassage(w http.ResponseWriter, r *http.Request) {
 		message := requestUrn + " is not valid CTS."
 		result := NodeResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
 		result.Service = "/texts"
-		resultJSON, _ := json.Marshal(result)
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		fmt.Fprintln(w, string(resultJSON))
+		writePassage(w, r, result)
 		return
 	}
 	workResult := ParseWork(CTSParams{Sourcetext: sourcetext})
@@ -1269,7 +1546,5 @@ func ReturnPassage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	result.Service = "/texts"
-	resultJSON, _ := json.Marshal(result)
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	fmt.Fprintln(w, string(resultJSON))
+	writePassage(w, r, result)
 }
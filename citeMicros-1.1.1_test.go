@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUrnContains(t *testing.T) {
+	tests := []struct {
+		request, node string
+		want          bool
+	}{
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:ns:tg.wk.ed:1.1", true},
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:ns:tg.wk.ed:1", true},
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:ns:tg.wk.ed:10.1", false},
+		{"urn:cts:ns:tg.wk.ed:1.1", "urn:cts:ns:tg.wk.ed:1", false},
+		{"urn:cts:ns:tg.wk.ed:", "urn:cts:ns:tg.wk.ed:2.3", true},
+		{"urn:cts:ns:tg.wk:1", "urn:cts:ns:tg.wk.ed2:1.4", true},
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:ns:tg.wk.ed.tokens:1.1.2", true},
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:ns:tg.wk.ed2:1.1", false},
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:other:tg.wk.ed:1.1", false},
+		{"urn:cts:ns:tg.wk.ed:1@μῆνιν", "urn:cts:ns:tg.wk.ed:1.1", true},
+		{"urn:cts:ns", "urn:cts:ns:tg.wk.ed:1.1", false},
+	}
+	for _, test := range tests {
+		if got := urnContains(test.request, test.node); got != test.want {
+			t.Errorf("urnContains(%q, %q) = %v; want %v", test.request, test.node, got, test.want)
+		}
+	}
+}
+
+var scopeURNs = []string{
+	"urn:cts:ns:tg.wk.ed:1.1",
+	"urn:cts:ns:tg.wk.ed:1.2",
+	"urn:cts:ns:tg.wk.ed:2.1",
+	"urn:cts:ns:tg.wk.ed:2.2",
+	"urn:cts:ns:tg.wk.ed:3.1",
+	"urn:cts:ns:tg.wk.tr:1.1",
+	"urn:cts:ns:tg.wk.tr:2.1",
+	"urn:cts:ns:tg.wk.tr:3.1",
+}
+
+func TestScopeIndices(t *testing.T) {
+	tests := []struct {
+		request string
+		want    []int
+	}{
+		{"urn:cts:ns:tg.wk.ed:1", []int{0, 1}},
+		{"urn:cts:ns:tg.wk.ed:2.2", []int{3}},
+		{"urn:cts:ns:tg.wk.ed:", []int{0, 1, 2, 3, 4}},
+		{"urn:cts:ns:tg.wk.ed:1.2-2.1", []int{1, 2}},
+		{"urn:cts:ns:tg.wk.ed:1-2", []int{0, 1, 2, 3}},
+		{"urn:cts:ns:tg.wk.ed:2.2-9", []int{3, 4}},
+		{"urn:cts:ns:tg.wk:2-3", []int{2, 3, 4, 6, 7}},
+		{"urn:cts:ns:tg.wk.ed:4", nil},
+	}
+	for _, test := range tests {
+		if got := scopeIndices(scopeURNs, test.request); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("scopeIndices(%q) = %v; want %v", test.request, got, test.want)
+		}
+	}
+}
+
+func TestExplicitScopeIndices(t *testing.T) {
+	work := Work{URN: []string{
+		"urn:cts:ns:tg.wk.ed:1.1",
+		"urn:cts:ns:tg.wk.ed:1.2",
+		"urn:cts:ns:tg.wk.ed.ex:1.1.1",
+		"urn:cts:ns:tg.wk.ed.tokens:1.1.1",
+		"urn:cts:ns:tg.wk.ed.tokens:1.1.2",
+		"urn:cts:ns:tg.wk.ed.lemmata:1.1.1",
+	}, Text: make([]string, 6),
+		Derived: []string{"urn:cts:ns:tg.wk.ed.tokens", "urn:cts:ns:tg.wk.ed.lemmata"}}
+	tests := []struct {
+		request string
+		want    []int
+	}{
+		{"urn:cts:ns:tg.wk.ed:1", []int{0, 1, 2}},
+		{"urn:cts:ns:tg.wk:", []int{0, 1, 2}},
+		{"urn:cts:ns:tg.wk.ed.ex:1.1", []int{2}},
+		{"urn:cts:ns:tg.wk.ed.tokens:1.1", []int{3, 4}},
+		{"urn:cts:ns:tg.wk.ed.lemmata:", []int{5}},
+		{"urn:cts:ns:tg.wk.ed:1.1-1.2", []int{0, 1, 2}},
+	}
+	for _, test := range tests {
+		if got := explicitScopeIndices(work, test.request); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("explicitScopeIndices(%q) = %v; want %v", test.request, got, test.want)
+		}
+	}
+}
+
+func TestCorpusStats(t *testing.T) {
+	work := Work{URN: []string{
+		"urn:cts:ns:tg.wk.ed:1.1",
+		"urn:cts:ns:tg.wk.ed:1.2",
+		"urn:cts:ns:tg.wk.ed.ex:1.1.1",
+		"urn:cts:ns:tg.wk.ed.tokens:1.1.1",
+		"urn:cts:ns:tg.wk.ed.tokens:1.1.2",
+	}, Text: []string{"arma virumque", "cano, arma", "arma virumque", "arma", "virumque"},
+		Derived: []string{"urn:cts:ns:tg.wk.ed.tokens"}}
+	tests := []struct {
+		request string
+		want    []TextStats
+	}{
+		{"", []TextStats{
+			{URN: "urn:cts:ns:tg.wk.ed:", Nodes: 2, Tokens: 4, Characters: 23, DistinctWords: 3, CitationDepth: 2, FirstReference: "1.1", LastReference: "1.2"},
+			{URN: "urn:cts:ns:tg.wk.ed.ex:", Nodes: 1, Tokens: 2, Characters: 13, DistinctWords: 2, CitationDepth: 3, FirstReference: "1.1.1", LastReference: "1.1.1"},
+		}},
+		{"urn:cts:ns:tg.wk.ed:1.2", []TextStats{
+			{URN: "urn:cts:ns:tg.wk.ed:", Nodes: 1, Tokens: 2, Characters: 10, DistinctWords: 2, CitationDepth: 2, FirstReference: "1.2", LastReference: "1.2"},
+		}},
+		{"urn:cts:ns:tg.wk.ed.tokens:", []TextStats{
+			{URN: "urn:cts:ns:tg.wk.ed.tokens:", Nodes: 2, Tokens: 2, Characters: 12, DistinctWords: 2, CitationDepth: 3, FirstReference: "1.1.1", LastReference: "1.1.2"},
+		}},
+	}
+	for _, test := range tests {
+		if got := CorpusStats(work, test.request); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("CorpusStats(%q) = %+v; want %+v", test.request, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type CiteProperty struct {
+	URN        string   `json:"urn"`
+	Label      string   `json:"label"`
+	Type       string   `json:"type"`
+	Vocabulary []string `json:"vocabulary,omitempty"`
+}
+
+type CiteCollection struct {
+	URN               string         `json:"urn"`
+	Description       string         `json:"description"`
+	LabellingProperty string         `json:"labellingProperty"`
+	OrderingProperty  string         `json:"orderingProperty,omitempty"`
+	License           string         `json:"license"`
+	Properties        []CiteProperty `json:"properties"`
+}
+
+type CitePropertyValue struct {
+	Property string      `json:"property"`
+	Type     string      `json:"type"`
+	Value    interface{} `json:"value"`
+	Raw      string      `json:"-"`
+}
+
+type CiteObject struct {
+	URN        string              `json:"urn"`
+	Label      string              `json:"label"`
+	Properties []CitePropertyValue `json:"properties"`
+}
+
+type CiteLibrary struct {
+	Collections   []CiteCollection
+	Objects       []CiteObject
+	InvalidValues []InvalidValue
+}
+
+type CollectionsResponse struct {
+	RequestUrn  []string         `json:"requestUrn"`
+	Status      string           `json:"status"`
+	Service     string           `json:"service"`
+	Message     string           `json:"message,omitempty"`
+	Collections []CiteCollection `json:"collections"`
+	Objects     []CiteObject     `json:"objects,omitempty"`
+}
+
+type ObjectsResponse struct {
+	RequestUrn []string     `json:"requestUrn"`
+	Status     string       `json:"status"`
+	Service    string       `json:"service"`
+	Message    string       `json:"message,omitempty"`
+	Objects    []CiteObject `json:"objects"`
+}
+
+// propertyURN builds the URN of property id in collection.
+func propertyURN(collection string, id string) string {
+	return strings.TrimSuffix(collection, ":") + "." + id + ":"
+}
+
+// typedValue converts a citedata value to the JSON type of its property.
+// Values that do not convert are returned unchanged.
+func typedValue(raw string, propertyType string) interface{} {
+	switch propertyType {
+	case "Number":
+		if number, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err == nil {
+			return number
+		}
+	case "Boolean":
+		if boolean, err := strconv.ParseBool(strings.TrimSpace(raw)); err == nil {
+			return boolean
+		}
+	}
+	return raw
+}
+
+// ParseCollections reads #!citecollections, #!citeproperties and every
+// #!citedata block of a CEX source. Each #!citedata block starts with a
+// header of property ids; the collection follows from the object URNs.
+// Values that do not fit their declared type are kept as strings and listed
+// in InvalidValues.
+func ParseCollections(p CTSParams) CiteLibrary {
+	data, err := getContent(p.Sourcetext)
+	if err != nil {
+		return CiteLibrary{}
+	}
+	return parseCollections(string(data))
+}
+
+func parseCollections(str string) CiteLibrary {
+	var library CiteLibrary
+	for _, block := range cexBlocks(str, "citecollections") {
+		for _, line := range cexRecords(block) {
+			if !isCite2URN(line[0]) {
+				continue
+			}
+			for len(line) < 5 {
+				line = append(line, "")
+			}
+			library.Collections = append(library.Collections, CiteCollection{URN: line[0],
+				Description:       line[1],
+				LabellingProperty: line[2],
+				OrderingProperty:  line[3],
+				License:           line[4],
+				Properties:        []CiteProperty{}})
+		}
+	}
+	for _, block := range cexBlocks(str, "citeproperties") {
+		for _, line := range cexRecords(block) {
+			if !isCite2URN(line[0]) {
+				continue
+			}
+			for len(line) < 4 {
+				line = append(line, "")
+			}
+			property := CiteProperty{URN: line[0], Label: line[1], Type: line[2]}
+			for _, term := range strings.Split(line[3], ",") {
+				if strings.TrimSpace(term) != "" {
+					property.Vocabulary = append(property.Vocabulary, strings.TrimSpace(term))
+				}
+			}
+			for i := range library.Collections {
+				if library.Collections[i].URN == cite2Collection(property.URN) {
+					library.Collections[i].Properties = append(library.Collections[i].Properties, property)
+				}
+			}
+		}
+	}
+	for _, block := range cexBlocks(str, "citedata") {
+		records := cexRecords(block)
+		if len(records) < 2 {
+			continue
+		}
+		header := records[0]
+		urnColumn := -1
+		for i := range header {
+			if header[i] == "urn" {
+				urnColumn = i
+			}
+		}
+		if urnColumn < 0 {
+			continue
+		}
+		for _, line := range records[1:] {
+			if len(line) <= urnColumn || !isCite2URN(line[urnColumn]) {
+				continue
+			}
+			object := CiteObject{URN: line[urnColumn]}
+			collection, _ := collectionFor(library, object.URN)
+			for i := range header {
+				if i >= len(line) {
+					break
+				}
+				property := CiteProperty{URN: propertyURN(cite2Collection(object.URN), header[i]), Type: "String"}
+				for _, declared := range collection.Properties {
+					if declared.URN == property.URN {
+						property = declared
+					}
+				}
+				if message := valueProblem(property, line[i]); message != "" {
+					library.InvalidValues = append(library.InvalidValues, InvalidValue{Object: object.URN, Property: property.URN, Type: property.Type, Value: line[i], Message: message})
+				}
+				object.Properties = append(object.Properties, CitePropertyValue{Property: property.URN, Type: property.Type, Value: typedValue(line[i], property.Type), Raw: line[i]})
+				if property.URN == collection.LabellingProperty {
+					object.Label = line[i]
+				}
+			}
+			library.Objects = append(library.Objects, object)
+		}
+	}
+	return library
+}
+
+// collectionFor returns the collection an object or collection URN belongs to.
+func collectionFor(library CiteLibrary, urn string) (CiteCollection, bool) {
+	for i := range library.Collections {
+		if library.Collections[i].URN == cite2Collection(urn) {
+			return library.Collections[i], true
+		}
+	}
+	return CiteCollection{}, false
+}
+
+// collectionObjects returns the objects of collection in source order.
+func collectionObjects(library CiteLibrary, collection string) []CiteObject {
+	var result []CiteObject
+	for _, object := range library.Objects {
+		if cite2Collection(object.URN) == collection {
+			result = append(result, object)
+		}
+	}
+	return result
+}
+
+// orderedObjects returns the objects of collection sorted by its ordering
+// property, or in source order if it has none.
+func orderedObjects(library CiteLibrary, collection string) []CiteObject {
+	result := collectionObjects(library, collection)
+	ordering := ""
+	if c, ok := collectionFor(library, collection); ok {
+		ordering = c.OrderingProperty
+	}
+	if ordering == "" {
+		return result
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, _ := propertyValue(result[i], ordering).(float64)
+		b, _ := propertyValue(result[j], ordering).(float64)
+		return a < b
+	})
+	return result
+}
+
+// propertyValue returns the typed value of property on object, or nil.
+func propertyValue(object CiteObject, property string) interface{} {
+	for _, value := range object.Properties {
+		if value.Property == property {
+			return value.Value
+		}
+	}
+	return nil
+}
+
+// collectionsMatching returns the collections a request URN refers to. A URN
+// without version (urn:cite2:hmt:msA:) matches every version of a collection.
+func collectionsMatching(library CiteLibrary, urn Cite2Urn) []CiteCollection {
+	var result []CiteCollection
+	for _, collection := range library.Collections {
+		c, err := ParseCite2Urn(collection.URN)
+		if err != nil || c.Namespace != urn.Namespace || c.Collection != urn.Collection {
+			continue
+		}
+		if urn.Version == "" || urn.Version == c.Version {
+			result = append(result, collection)
+		}
+	}
+	return result
+}
+
+// selectObjects returns the objects of a collection an object, range or
+// collection URN selects, limited to the requested property if the URN
+// carries a property extension.
+func selectObjects(library CiteLibrary, collection CiteCollection, urn Cite2Urn) []CiteObject {
+	var result []CiteObject
+	objects := orderedObjects(library, collection.URN)
+	inRange := false
+	for _, object := range objects {
+		id := strings.Split(object.URN, ":")[4]
+		switch {
+		case urn.IsCollection():
+		case urn.IsRange():
+			if id == urn.RangeBegin {
+				inRange = true
+			}
+			if !inRange {
+				continue
+			}
+			if id == urn.RangeEnd {
+				inRange = false
+			}
+		case id != urn.Object:
+			continue
+		}
+		if urn.Property != "" {
+			property := strings.TrimSuffix(collection.URN, ":") + "." + urn.Property + ":"
+			selected := CiteObject{URN: object.URN, Label: object.Label}
+			for _, value := range object.Properties {
+				if value.Property == property {
+					selected.Properties = append(selected.Properties, value)
+				}
+			}
+			object = selected
+		}
+		result = append(result, object)
+	}
+	return result
+}
+
+func ReturnCollections(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	urn, err := ParseCite2Urn(requestUrn)
+	var result CollectionsResponse
+	switch {
+	case requestUrn != "" && err != nil:
+		result = CollectionsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+	case requestUrn == "":
+		library := ParseCollections(CTSParams{Sourcetext: sourcetext})
+		result = CollectionsResponse{RequestUrn: []string{}, Status: "Success", Collections: library.Collections}
+	default:
+		library := ParseCollections(CTSParams{Sourcetext: sourcetext})
+		collections := collectionsMatching(library, urn)
+		switch {
+		case len(collections) == 0:
+			message := "No results for " + requestUrn
+			result = CollectionsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = CollectionsResponse{RequestUrn: []string{requestUrn}, Status: "Success", Collections: collections}
+			for _, collection := range collections {
+				result.Objects = append(result.Objects, orderedObjects(library, collection.URN)...)
+			}
+		}
+	}
+	result.Service = "/collections"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
+
+func ReturnObjects(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	urn, err := ParseCite2Urn(requestUrn)
+	var result ObjectsResponse
+	switch {
+	case err != nil:
+		result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+	default:
+		library := ParseCollections(CTSParams{Sourcetext: sourcetext})
+		var objects []CiteObject
+		for _, collection := range collectionsMatching(library, urn) {
+			objects = append(objects, selectObjects(library, collection, urn)...)
+		}
+		switch {
+		case len(objects) == 0:
+			message := "No results for " + requestUrn
+			result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Success", Objects: objects}
+		}
+	}
+	result.Service = "/objects"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
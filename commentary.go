@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// commentsOn is the verb of relations from a commentary passage to the
+// passage it comments on.
+const commentsOn = "urn:cite2:cite:verbs.v1:commentsOn"
+
+type CommentaryPassage struct {
+	URN  string `json:"urn"`
+	Text string `json:"text"`
+}
+
+type Commentary struct {
+	Commentary string              `json:"commentary"`
+	Target     string              `json:"target"`
+	Passages   []CommentaryPassage `json:"passages"`
+}
+
+type CommentaryResponse struct {
+	RequestUrn []string     `json:"requestUrn"`
+	Status     string       `json:"status"`
+	Service    string       `json:"service"`
+	Message    string       `json:"message,omitempty"`
+	Commentary []Commentary `json:"commentary"`
+}
+
+// CommentaryFor returns the commentsOn relations whose target is contained
+// in the request passage (including subreferences on its nodes) or contains
+// it, with the text of the commenting passages. Relations whose subject is
+// not a CTS URN are skipped.
+func CommentaryFor(relations []CiteRelation, work Work, request string) []Commentary {
+	var result []Commentary
+	for _, relation := range relations {
+		if relation.Verb != commentsOn || !isCTSURN(relation.Subject) {
+			continue
+		}
+		if !relationContains(request, relation.Object) && !relationContains(relation.Object, request) {
+			continue
+		}
+		commentary := Commentary{Commentary: relation.Subject, Target: relation.Object, Passages: []CommentaryPassage{}}
+		for _, i := range explicitScopeIndices(work, relation.Subject) {
+			commentary.Passages = append(commentary.Passages, CommentaryPassage{URN: work.URN[i], Text: work.Text[i]})
+		}
+		result = append(result, commentary)
+	}
+	return result
+}
+
+func ReturnCommentary(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	var result CommentaryResponse
+	switch {
+	case isCTSURN(requestUrn) != true:
+		message := requestUrn + " is not valid CTS."
+		result = CommentaryResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		commentary := CommentaryFor(ParseRelations(CTSParams{Sourcetext: sourcetext}), ParseWork(CTSParams{Sourcetext: sourcetext}), requestUrn)
+		switch {
+		case len(commentary) == 0:
+			message := "No results for " + requestUrn
+			result = CommentaryResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = CommentaryResponse{RequestUrn: []string{requestUrn}, Status: "Success", Commentary: commentary}
+		}
+	}
+	result.Service = "/commentary"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
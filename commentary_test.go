@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestCommentaryFor(t *testing.T) {
+	work := Work{URN: []string{
+		"urn:cts:ns:tg.wk.ed:1.1",
+		"urn:cts:ns:tg.wk.ed:1.2",
+		"urn:cts:ns:tg.comm.ed:1",
+	}, Text: []string{"a", "b", "note"}}
+	relations := []CiteRelation{
+		{Subject: "urn:cts:ns:tg.comm.ed:1", Verb: commentsOn, Object: "urn:cts:ns:tg.wk.ed:1.2"},
+		{Subject: "urn:cts:ns", Verb: commentsOn, Object: "urn:cts:ns:tg.wk.ed:1.2"},
+		{Subject: "urn:cite2:ns:notes.v1:1", Verb: commentsOn, Object: "urn:cts:ns:tg.wk.ed:1.2"},
+		{Subject: "urn:cts:ns:tg.comm.ed:1", Verb: "urn:cite2:cite:verbs.v1:illustrates", Object: "urn:cts:ns:tg.wk.ed:1.2"},
+		{Subject: "urn:cts:ns:tg.comm.ed:1", Verb: commentsOn, Object: "urn:cts:ns:tg.wk.ed:2.1"},
+	}
+	tests := []struct {
+		request string
+		want    int
+	}{
+		{"urn:cts:ns:tg.wk.ed:1", 1},
+		{"urn:cts:ns:tg.wk.ed:1.2@b", 1},
+		{"urn:cts:ns:tg.wk.ed:1.1", 0},
+		{"urn:cts:ns:tg.wk.ed:", 2},
+	}
+	for _, test := range tests {
+		result := CommentaryFor(relations, work, test.request)
+		if len(result) != test.want {
+			t.Errorf("CommentaryFor(%q) returned %d commentaries; want %d", test.request, len(result), test.want)
+		}
+		for _, commentary := range result {
+			if len(commentary.Passages) != 1 || commentary.Passages[0].Text != "note" {
+				t.Errorf("CommentaryFor(%q) passages = %v; want the note", test.request, commentary.Passages)
+			}
+		}
+	}
+}
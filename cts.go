@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const ctsNamespace = "http://chs.harvard.edu/xmlns/cts"
+const teiNamespace = "http://www.tei-c.org/ns/1.0"
+
+type CTSRequest struct {
+	Name  string `xml:"requestName"`
+	URN   string `xml:"requestUrn,omitempty"`
+	Level string `xml:"requestLevel,omitempty"`
+}
+
+type CTSReply struct {
+	TextInventory *TextInventory `xml:"TextInventory,omitempty"`
+	URN           string         `xml:"urn,omitempty"`
+	Passage       *CTSPassage    `xml:"passage,omitempty"`
+	Reff          *CTSReff       `xml:"reff,omitempty"`
+	PrevNext      *CTSPrevNext   `xml:"prevnext,omitempty"`
+	Label         *CTSLabel      `xml:"label,omitempty"`
+}
+
+type CTSResponse struct {
+	XMLName xml.Name
+	Xmlns   string     `xml:"xmlns,attr"`
+	Request CTSRequest `xml:"request"`
+	Reply   CTSReply   `xml:"reply"`
+}
+
+type CTSError struct {
+	XMLName xml.Name `xml:"CTSError"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Message string   `xml:"message"`
+	Code    int      `xml:"code"`
+}
+
+type CTSLangString struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type TextInventory struct {
+	Version    string         `xml:"tiversion,attr"`
+	Textgroups []CTSTextgroup `xml:"textgroup"`
+}
+
+type CTSTextgroup struct {
+	URN       string        `xml:"urn,attr"`
+	Groupname CTSLangString `xml:"groupname"`
+	Works     []CTSWork     `xml:"work"`
+}
+
+type CTSWork struct {
+	URN      string        `xml:"urn,attr"`
+	Lang     string        `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title    CTSLangString `xml:"title"`
+	Editions []CTSEdition  `xml:"edition"`
+}
+
+type CTSEdition struct {
+	URN       string        `xml:"urn,attr"`
+	Label     CTSLangString `xml:"label"`
+	Online    *struct{}     `xml:"online,omitempty"`
+	Citation  string        `xml:"citation,omitempty"`
+	Exemplars []CTSExemplar `xml:"exemplar"`
+}
+
+type CTSExemplar struct {
+	URN      string        `xml:"urn,attr"`
+	Label    CTSLangString `xml:"label"`
+	Online   *struct{}     `xml:"online,omitempty"`
+	Citation string        `xml:"citation,omitempty"`
+}
+
+type CTSPassage struct {
+	Inner string `xml:",innerxml"`
+}
+
+type CTSReff struct {
+	URN []string `xml:"urn"`
+}
+
+type CTSPrevNext struct {
+	Prev CTSUrnList `xml:"prev"`
+	Next CTSUrnList `xml:"next"`
+}
+
+type CTSUrnList struct {
+	URN []string `xml:"urn"`
+}
+
+type CTSLabel struct {
+	Groupname CTSLangString  `xml:"groupname"`
+	Title     CTSLangString  `xml:"title"`
+	Version   *CTSLangString `xml:"version,omitempty"`
+	Exemplar  *CTSLangString `xml:"exemplar,omitempty"`
+	Citation  string         `xml:"citation,omitempty"`
+}
+
+var ctsRequests = []string{"GetCapabilities", "GetPassage", "GetValidReff", "GetPrevNextUrn", "GetFirstUrn", "GetLabel"}
+
+// completeCatalog returns the catalog plus minimal entries for versions that
+// appear in the data but not in the catalog.
+func completeCatalog(catalog []CatalogEntry, work Work) []CatalogEntry {
+	result := append([]CatalogEntry(nil), catalog...)
+	for i := range work.URN {
+		if _, ok := catalogEntryFor(result, work.URN[i]); !ok {
+			stem := ctsStem(work.URN[i])
+			result = append(result, CatalogEntry{URN: stem + ":", GroupName: stem, WorkTitle: stem, VersionLabel: stem, Online: true})
+		}
+	}
+	return result
+}
+
+// BuildTextInventory arranges catalog entries as CTS textgroups, works,
+// editions and exemplars.
+func BuildTextInventory(catalog []CatalogEntry) TextInventory {
+	inventory := TextInventory{Version: "5.0.rc.1"}
+	for _, entry := range catalog {
+		parts := strings.Split(entry.URN, ":")
+		components := strings.Split(parts[3], ".")
+		if len(components) < 3 {
+			continue
+		}
+		groupUrn := strings.Join(parts[0:3], ":") + ":" + components[0] + ":"
+		workUrn := strings.Join(parts[0:3], ":") + ":" + strings.Join(components[0:2], ".") + ":"
+		editionUrn := strings.Join(parts[0:3], ":") + ":" + strings.Join(components[0:3], ".") + ":"
+		g := -1
+		for i := range inventory.Textgroups {
+			if inventory.Textgroups[i].URN == groupUrn {
+				g = i
+			}
+		}
+		if g < 0 {
+			inventory.Textgroups = append(inventory.Textgroups, CTSTextgroup{URN: groupUrn, Groupname: CTSLangString{Lang: "eng", Text: entry.GroupName}})
+			g = len(inventory.Textgroups) - 1
+		}
+		group := &inventory.Textgroups[g]
+		wk := -1
+		for i := range group.Works {
+			if group.Works[i].URN == workUrn {
+				wk = i
+			}
+		}
+		if wk < 0 {
+			group.Works = append(group.Works, CTSWork{URN: workUrn, Lang: entry.Language, Title: CTSLangString{Lang: "eng", Text: entry.WorkTitle}})
+			wk = len(group.Works) - 1
+		}
+		work := &group.Works[wk]
+		e := -1
+		for i := range work.Editions {
+			if work.Editions[i].URN == editionUrn {
+				e = i
+			}
+		}
+		var online *struct{}
+		if entry.Online {
+			online = &struct{}{}
+		}
+		switch {
+		case len(components) == 3 && e < 0:
+			work.Editions = append(work.Editions, CTSEdition{URN: editionUrn, Label: CTSLangString{Lang: "eng", Text: entry.VersionLabel}, Online: online, Citation: entry.CitationScheme})
+		case len(components) == 3:
+			work.Editions[e].Label = CTSLangString{Lang: "eng", Text: entry.VersionLabel}
+			work.Editions[e].Online = online
+			work.Editions[e].Citation = entry.CitationScheme
+		default:
+			if e < 0 {
+				work.Editions = append(work.Editions, CTSEdition{URN: editionUrn, Label: CTSLangString{Lang: "eng", Text: entry.VersionLabel}})
+				e = len(work.Editions) - 1
+			}
+			work.Editions[e].Exemplars = append(work.Editions[e].Exemplars, CTSExemplar{URN: entry.URN, Label: CTSLangString{Lang: "eng", Text: entry.ExemplarLabel}, Online: online, Citation: entry.CitationScheme})
+		}
+	}
+	return inventory
+}
+
+// ctsPassageIndices resolves a request URN to the nodes of a single version,
+// the first one in the source if the URN is notional.
+func ctsPassageIndices(work Work, requestUrn string) []int {
+	var result []int
+	for _, i := range explicitScopeIndices(work, requestUrn) {
+		if len(result) > 0 && ctsStem(work.URN[i]) != ctsStem(work.URN[result[0]]) {
+			continue
+		}
+		result = append(result, i)
+	}
+	return result
+}
+
+// truncateReference cuts the passage component of a CTS URN to depth levels.
+func truncateReference(urn string, depth int) string {
+	reference := strings.Split(ctsReference(urn), ".")
+	if depth < len(reference) {
+		reference = reference[0:depth]
+	}
+	return ctsStem(urn) + ":" + strings.Join(reference, ".")
+}
+
+func referenceDepth(urn string) int {
+	if ctsReference(urn) == "" {
+		return 0
+	}
+	return len(strings.Split(strings.Split(ctsReference(urn), "-")[0], "."))
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// passageXML renders nodes as a TEI fragment with the textparts nested
+// according to their citation hierarchy.
+func passageXML(work Work, indices []int, levels []string) string {
+	var urns, texts []string
+	for _, i := range indices {
+		urns = append(urns, work.URN[i])
+		texts = append(texts, work.Text[i])
+	}
+	var tei strings.Builder
+	tei.WriteString(`<TEI xmlns="` + teiNamespace + `"><text><body>`)
+	if len(indices) > 0 {
+		tei.WriteString(`<div type="edition" n="` + xmlEscape(ctsStem(work.URN[indices[0]])+":") + `">`)
+		tei.WriteString(teiTextparts(urns, texts, levels))
+		tei.WriteString(`</div>`)
+	}
+	tei.WriteString(`</body></text></TEI>`)
+	return tei.String()
+}
+
+func ReturnCTS(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	query := r.URL.Query()
+	request := ""
+	for _, name := range ctsRequests {
+		if strings.EqualFold(query.Get("request"), name) {
+			request = name
+		}
+	}
+	requestUrn := query.Get("urn")
+	result := CTSResponse{XMLName: xml.Name{Local: request}, Xmlns: ctsNamespace, Request: CTSRequest{Name: request, URN: requestUrn, Level: query.Get("level")}}
+	var ctsError *CTSError
+	switch {
+	case request == "":
+		ctsError = &CTSError{Message: "Invalid request name " + query.Get("request") + ".", Code: 1}
+	case request == "GetCapabilities":
+		workResult := ParseWork(CTSParams{Sourcetext: sourcetext})
+		inventory := BuildTextInventory(completeCatalog(ParseCatalog(CTSParams{Sourcetext: sourcetext}), workResult))
+		result.Reply.TextInventory = &inventory
+	case isCTSURN(requestUrn) != true:
+		ctsError = &CTSError{Message: requestUrn + " is not valid CTS.", Code: 2}
+	case request == "GetLabel":
+		catalog := completeCatalog(ParseCatalog(CTSParams{Sourcetext: sourcetext}), ParseWork(CTSParams{Sourcetext: sourcetext}))
+		var entry CatalogEntry
+		found := false
+		for i := range catalog {
+			if urnContains(ctsStem(requestUrn)+":", catalog[i].URN) {
+				entry = catalog[i]
+				found = true
+				break
+			}
+		}
+		if !found {
+			ctsError = &CTSError{Message: "No results for " + requestUrn, Code: 3}
+			break
+		}
+		label := CTSLabel{Groupname: CTSLangString{Lang: "eng", Text: entry.GroupName}, Title: CTSLangString{Lang: "eng", Text: entry.WorkTitle}}
+		depth := len(strings.Split(strings.Split(requestUrn, ":")[3], "."))
+		if depth > 2 {
+			label.Version = &CTSLangString{Lang: "eng", Text: entry.VersionLabel}
+			label.Citation = entry.CitationScheme
+		}
+		if depth > 3 {
+			label.Exemplar = &CTSLangString{Lang: "eng", Text: entry.ExemplarLabel}
+		}
+		result.Reply.Label = &label
+	default:
+		workResult := ParseWork(CTSParams{Sourcetext: sourcetext})
+		indices := ctsPassageIndices(workResult, requestUrn)
+		if len(indices) == 0 {
+			ctsError = &CTSError{Message: "No results for " + requestUrn, Code: 3}
+			break
+		}
+		first := indices[0]
+		last := indices[len(indices)-1]
+		switch request {
+		case "GetPassage":
+			result.Reply.URN = requestUrn
+			entry := teiEntry(ParseCatalog(CTSParams{Sourcetext: sourcetext}), workResult.URN[first])
+			result.Reply.Passage = &CTSPassage{Inner: passageXML(workResult, indices, citationLevels(entry.CitationScheme))}
+		case "GetValidReff":
+			level := 0
+			for _, i := range indices {
+				if depth := referenceDepth(workResult.URN[i]); depth > level {
+					level = depth
+				}
+			}
+			if query.Get("level") != "" {
+				requested, err := strconv.Atoi(query.Get("level"))
+				if err != nil || requested < 1 {
+					ctsError = &CTSError{Message: "Invalid level " + query.Get("level") + ".", Code: 4}
+					break
+				}
+				level = requested
+			}
+			reff := CTSReff{}
+			for _, i := range indices {
+				urn := truncateReference(workResult.URN[i], level)
+				if !contains(reff.URN, urn) {
+					reff.URN = append(reff.URN, urn)
+				}
+			}
+			result.Reply.Reff = &reff
+		case "GetPrevNextUrn":
+			depth := referenceDepth(requestUrn)
+			if depth == 0 {
+				depth = referenceDepth(workResult.URN[first])
+			}
+			prevnext := CTSPrevNext{}
+			if first > 0 && ctsStem(workResult.URN[first-1]) == ctsStem(workResult.URN[first]) {
+				prevnext.Prev.URN = []string{truncateReference(workResult.URN[first-1], depth)}
+			}
+			if last < len(workResult.URN)-1 && ctsStem(workResult.URN[last+1]) == ctsStem(workResult.URN[last]) {
+				prevnext.Next.URN = []string{truncateReference(workResult.URN[last+1], depth)}
+			}
+			result.Reply.PrevNext = &prevnext
+		case "GetFirstUrn":
+			result.Reply.URN = truncateReference(workResult.URN[first], referenceDepth(requestUrn)+1)
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	var resultXML []byte
+	switch {
+	case ctsError != nil:
+		ctsError.Xmlns = ctsNamespace
+		resultXML, _ = xml.MarshalIndent(ctsError, "", "  ")
+	default:
+		resultXML, _ = xml.MarshalIndent(result, "", "  ")
+	}
+	fmt.Fprintln(w, xml.Header+string(resultXML))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTruncateReference(t *testing.T) {
+	tests := []struct {
+		urn   string
+		depth int
+		want  string
+	}{
+		{"urn:cts:ns:tg.wk.ed:1.2.3", 2, "urn:cts:ns:tg.wk.ed:1.2"},
+		{"urn:cts:ns:tg.wk.ed:1.2.3", 1, "urn:cts:ns:tg.wk.ed:1"},
+		{"urn:cts:ns:tg.wk.ed:1.2", 3, "urn:cts:ns:tg.wk.ed:1.2"},
+		{"urn:cts:ns:tg.wk.ed:1.2@μῆνιν", 1, "urn:cts:ns:tg.wk.ed:1"},
+	}
+	for _, test := range tests {
+		if got := truncateReference(test.urn, test.depth); got != test.want {
+			t.Errorf("truncateReference(%q, %d) = %q; want %q", test.urn, test.depth, got, test.want)
+		}
+	}
+}
+
+func TestReferenceDepth(t *testing.T) {
+	tests := []struct {
+		urn  string
+		want int
+	}{
+		{"urn:cts:ns:tg.wk.ed:", 0},
+		{"urn:cts:ns:tg.wk.ed:1", 1},
+		{"urn:cts:ns:tg.wk.ed:1.2.3", 3},
+		{"urn:cts:ns:tg.wk.ed:1.2-3.4", 2},
+	}
+	for _, test := range tests {
+		if got := referenceDepth(test.urn); got != test.want {
+			t.Errorf("referenceDepth(%q) = %d; want %d", test.urn, got, test.want)
+		}
+	}
+}
+
+func TestCtsPassageIndices(t *testing.T) {
+	work := Work{URN: scopeURNs, Text: make([]string, len(scopeURNs))}
+	tests := []struct {
+		request string
+		want    []int
+	}{
+		{"urn:cts:ns:tg.wk.ed:1", []int{0, 1}},
+		{"urn:cts:ns:tg.wk.tr:2.1", []int{6}},
+		{"urn:cts:ns:tg.wk:1", []int{0, 1}},
+		{"urn:cts:ns:tg.wk:3.1", []int{4}},
+		{"urn:cts:ns:tg.wk.ed:2.2-3.1", []int{3, 4}},
+		{"urn:cts:ns:tg.wk.ed:9", nil},
+	}
+	for _, test := range tests {
+		if got := ctsPassageIndices(work, test.request); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ctsPassageIndices(%q) = %v; want %v", test.request, got, test.want)
+		}
+	}
+}
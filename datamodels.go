@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Data models a collection can implement, as declared in #!datamodels.
+const (
+	dseModel         = "urn:cite2:cite:datamodels.v1:dsemodel"
+	orcaModel        = "urn:cite2:cite:datamodels.v1:orca"
+	commentaryModel  = "urn:cite2:cite:datamodels.v1:commentarymodel"
+	binaryImageModel = "urn:cite2:cite:datamodels.v1:binaryimg"
+)
+
+// modelNames labels the data models this server knows about.
+var modelNames = map[string]string{
+	dseModel:         "DSE",
+	orcaModel:        "ORCA",
+	commentaryModel:  "Commentary",
+	binaryImageModel: "Binary image",
+}
+
+// supportedModels are the data models whose collections this server reads.
+var supportedModels = map[string]bool{
+	dseModel:  true,
+	orcaModel: true,
+}
+
+type DataModel struct {
+	Collection  string `json:"collection"`
+	Model       string `json:"model"`
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Name        string `json:"name,omitempty"`
+	Supported   bool   `json:"supported"`
+}
+
+type DataModelsResponse struct {
+	Status     string      `json:"status"`
+	Service    string      `json:"service"`
+	Message    string      `json:"message,omitempty"`
+	DataModels []DataModel `json:"datamodels"`
+}
+
+// ParseDataModels reads the Collection#Model#Label#Description records of
+// every #!datamodels block, naming the models this server knows about and
+// marking those it implements.
+func ParseDataModels(p CTSParams) []DataModel {
+	data, err := getContent(p.Sourcetext)
+	if err != nil {
+		return nil
+	}
+	return parseDataModels(string(data))
+}
+
+func parseDataModels(str string) []DataModel {
+	var result []DataModel
+	for _, block := range cexBlocks(str, "datamodels") {
+		for _, line := range cexRecords(block) {
+			if !isCite2URN(line[0]) {
+				continue
+			}
+			for len(line) < 4 {
+				line = append(line, "")
+			}
+			model := DataModel{Collection: line[0], Model: strings.TrimSpace(line[1]), Label: line[2], Description: line[3]}
+			model.Name, model.Supported = modelNames[model.Model], supportedModels[model.Model]
+			result = append(result, model)
+		}
+	}
+	return result
+}
+
+// modelCollections returns the collections declared to implement model.
+func modelCollections(models []DataModel, model string) []string {
+	var result []string
+	for _, m := range models {
+		if m.Model == model && !contains(result, m.Collection) {
+			result = append(result, m.Collection)
+		}
+	}
+	return result
+}
+
+func ReturnDataModels(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	result := DataModelsResponse{Status: "Success", DataModels: []DataModel{}}
+	data, err := getContent(sourcetext)
+	switch {
+	case err != nil:
+		result = DataModelsResponse{Status: "Exception", Message: "Couldn't open connection."}
+	default:
+		result.DataModels = append(result.DataModels, parseDataModels(string(data))...)
+	}
+	result.Service = "/datamodels"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// DSERecord relates a text passage to the region of an image that shows it
+// and to the physical surface (folio, page) it is written on.
+type DSERecord struct {
+	URN      string     `json:"urn"`
+	Label    string     `json:"label"`
+	Passage  string     `json:"passage"`
+	ImageROI string     `json:"imageroi"`
+	Surface  string     `json:"surface"`
+	Image    *IIIFImage `json:"image,omitempty"`
+}
+
+type DSEResponse struct {
+	RequestUrn []string    `json:"requestUrn"`
+	Status     string      `json:"status"`
+	Service    string      `json:"service"`
+	Message    string      `json:"message,omitempty"`
+	Records    []DSERecord `json:"records"`
+}
+
+// propertyRaw returns the citedata value of the property with id on object.
+func propertyRaw(object CiteObject, collection string, id string) string {
+	for _, value := range object.Properties {
+		if value.Property == propertyURN(collection, id) {
+			return value.Raw
+		}
+	}
+	return ""
+}
+
+// ParseDSE reads the records of every collection #!datamodels declares to
+// implement the DSE model. Records take their passage, imageroi and surface
+// properties from the collection's citedata; images get their IIIF URLs if an
+// image service is configured, the built-in one at server.
+func ParseDSE(p CTSParams, server string) []DSERecord {
+	var result []DSERecord
+	library := ParseCollections(p)
+	config := LoadConfiguration("config.json")
+	for _, collection := range modelCollections(ParseDataModels(p), dseModel) {
+		for _, object := range collectionObjects(library, collection) {
+			record := DSERecord{URN: object.URN,
+				Label:    object.Label,
+				Passage:  propertyRaw(object, collection, "passage"),
+				ImageROI: propertyRaw(object, collection, "imageroi"),
+				Surface:  propertyRaw(object, collection, "surface")}
+			if image, err := ImageFor(record.ImageROI, config, server); err == nil {
+				record.Image = &image
+			}
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// returnDSE answers the /dse services with the records for which match holds
+// for the request URN. valid checks the kind of URN the service expects.
+func returnDSE(w http.ResponseWriter, r *http.Request, service string, valid func(string) bool, match func(requestUrn string, record DSERecord) bool) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	var result DSEResponse
+	switch {
+	case !valid(requestUrn):
+		message := requestUrn + " is not a valid URN for " + service + "."
+		result = DSEResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		var records []DSERecord
+		for _, record := range ParseDSE(CTSParams{Sourcetext: sourcetext}, serverBase(r)) {
+			if match(requestUrn, record) {
+				records = append(records, record)
+			}
+		}
+		switch {
+		case len(records) == 0:
+			message := "No results for " + requestUrn
+			result = DSEResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = DSEResponse{RequestUrn: []string{requestUrn}, Status: "Success", Records: records}
+		}
+	}
+	result.Service = service
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
+
+func ReturnDSEForText(w http.ResponseWriter, r *http.Request) {
+	returnDSE(w, r, "/dse/recordsForText", isCTSURN, func(requestUrn string, record DSERecord) bool {
+		return relationContains(requestUrn, record.Passage)
+	})
+}
+
+func ReturnDSEForImage(w http.ResponseWriter, r *http.Request) {
+	returnDSE(w, r, "/dse/recordsForImage", isCite2URN, func(requestUrn string, record DSERecord) bool {
+		return relationContains(requestUrn, record.ImageROI)
+	})
+}
+
+func ReturnDSEForSurface(w http.ResponseWriter, r *http.Request) {
+	returnDSE(w, r, "/dse/recordsForSurface", isCite2URN, func(requestUrn string, record DSERecord) bool {
+		return relationContains(requestUrn, record.Surface)
+	})
+}
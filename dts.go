@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+var dtsContext = map[string]string{
+	"@vocab": "https://www.w3.org/ns/hydra/core#",
+	"dc":     "http://purl.org/dc/terms/",
+	"dts":    "https://w3id.org/dts/api#",
+}
+
+type DTSEntryPoint struct {
+	Context     map[string]string `json:"@context"`
+	ID          string            `json:"@id"`
+	Type        string            `json:"@type"`
+	Collections string            `json:"collections"`
+	Documents   string            `json:"documents"`
+	Navigation  string            `json:"navigation"`
+}
+
+type DTSDublinCore struct {
+	Language string `json:"dc:language,omitempty"`
+}
+
+type DTSMember struct {
+	ID         string         `json:"@id"`
+	Type       string         `json:"@type"`
+	Title      string         `json:"title"`
+	TotalItems int            `json:"totalItems"`
+	DublinCore *DTSDublinCore `json:"dts:dublincore,omitempty"`
+	CiteDepth  int            `json:"dts:citeDepth,omitempty"`
+	Passage    string         `json:"dts:passage,omitempty"`
+	References string         `json:"dts:references,omitempty"`
+}
+
+type DTSCollection struct {
+	Context map[string]string `json:"@context"`
+	DTSMember
+	Member []DTSMember `json:"member,omitempty"`
+}
+
+type DTSRef struct {
+	Ref string `json:"dts:ref"`
+}
+
+type DTSNavigation struct {
+	Context   map[string]string `json:"@context"`
+	ID        string            `json:"@id"`
+	CiteDepth int               `json:"dts:citeDepth"`
+	Level     int               `json:"dts:level"`
+	Passage   string            `json:"dts:passage"`
+	Member    []DTSRef          `json:"member"`
+}
+
+type DTSStatus struct {
+	Context     string `json:"@context"`
+	Type        string `json:"@type"`
+	StatusCode  int    `json:"statusCode"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// dtsBase returns the path prefix of the DTS endpoints for the requested CEX.
+func dtsBase(r *http.Request) string {
+	if requestCEX := mux.Vars(r)["CEX"]; requestCEX != "" {
+		return "/" + requestCEX + "/dts"
+	}
+	return "/dts"
+}
+
+func writeDTS(w http.ResponseWriter, result interface{}) {
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/ld+json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
+
+func writeDTSError(w http.ResponseWriter, code int, description string) {
+	result := DTSStatus{Context: "http://www.w3.org/ns/hydra/context.jsonld", Type: "Status", StatusCode: code, Title: http.StatusText(code), Description: description}
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/ld+json; charset=utf-8")
+	w.WriteHeader(code)
+	fmt.Fprintln(w, string(resultJSON))
+}
+
+// citeDepth returns the deepest citation level of the nodes of a version.
+func citeDepth(work Work, version string) int {
+	depth := 0
+	for i := range work.URN {
+		if ctsStem(work.URN[i]) == ctsStem(version) && referenceDepth(work.URN[i]) > depth {
+			depth = referenceDepth(work.URN[i])
+		}
+	}
+	return depth
+}
+
+func dtsResource(entry CatalogEntry, work Work, base string) DTSMember {
+	title := entry.VersionLabel
+	if entry.ExemplarLabel != "" {
+		title = title + ", " + entry.ExemplarLabel
+	}
+	member := DTSMember{ID: entry.URN,
+		Type:       "Resource",
+		Title:      title,
+		CiteDepth:  citeDepth(work, entry.URN),
+		Passage:    base + "/document?id=" + url.QueryEscape(entry.URN),
+		References: base + "/navigation?id=" + url.QueryEscape(entry.URN)}
+	if entry.Language != "" {
+		member.DublinCore = &DTSDublinCore{Language: entry.Language}
+	}
+	return member
+}
+
+// DTSCollectionFor maps the text inventory to DTS collections: the root
+// collection "default" holds the textgroups, textgroups hold works and works
+// hold their versions and exemplars as readable resources.
+func DTSCollectionFor(id string, inventory TextInventory, catalog []CatalogEntry, work Work, base string) (DTSCollection, bool) {
+	result := DTSCollection{Context: dtsContext}
+	switch {
+	case id == "" || id == "default":
+		result.DTSMember = DTSMember{ID: "default", Type: "Collection", Title: "Texts"}
+		for _, group := range inventory.Textgroups {
+			result.Member = append(result.Member, DTSMember{ID: group.URN, Type: "Collection", Title: group.Groupname.Text, TotalItems: len(group.Works)})
+		}
+		result.TotalItems = len(result.Member)
+		return result, true
+	}
+	for _, group := range inventory.Textgroups {
+		if group.URN == id {
+			result.DTSMember = DTSMember{ID: group.URN, Type: "Collection", Title: group.Groupname.Text, TotalItems: len(group.Works)}
+			for _, wk := range group.Works {
+				items := 0
+				for _, edition := range wk.Editions {
+					items = items + 1 + len(edition.Exemplars)
+				}
+				result.Member = append(result.Member, DTSMember{ID: wk.URN, Type: "Collection", Title: wk.Title.Text, TotalItems: items})
+			}
+			return result, true
+		}
+		for _, wk := range group.Works {
+			if wk.URN == id {
+				result.DTSMember = DTSMember{ID: wk.URN, Type: "Collection", Title: wk.Title.Text}
+				for _, edition := range wk.Editions {
+					for _, urn := range append([]string{edition.URN}, exemplarURNs(edition)...) {
+						if entry, ok := catalogEntryFor(catalog, urn); ok {
+							result.Member = append(result.Member, dtsResource(entry, work, base))
+						}
+					}
+				}
+				result.TotalItems = len(result.Member)
+				return result, true
+			}
+		}
+	}
+	if entry, ok := catalogEntryFor(catalog, id); ok {
+		result.DTSMember = dtsResource(entry, work, base)
+		return result, true
+	}
+	return result, false
+}
+
+func exemplarURNs(edition CTSEdition) []string {
+	var result []string
+	for _, exemplar := range edition.Exemplars {
+		result = append(result, exemplar.URN)
+	}
+	return result
+}
+
+// dtsPassageUrn builds the CTS URN a DTS request addresses with id and either
+// ref or start and end.
+func dtsPassageUrn(id string, ref string, start string, end string) string {
+	switch {
+	case ref != "":
+		return ctsStem(id) + ":" + ref
+	case start != "" && end != "":
+		return ctsStem(id) + ":" + start + "-" + end
+	default:
+		return ctsStem(id) + ":"
+	}
+}
+
+func ReturnDTSEntryPoint(w http.ResponseWriter, r *http.Request) {
+	base := dtsBase(r)
+	writeDTS(w, DTSEntryPoint{Context: dtsContext,
+		ID:          base,
+		Type:        "EntryPoint",
+		Collections: base + "/collections",
+		Documents:   base + "/document",
+		Navigation:  base + "/navigation"})
+}
+
+func ReturnDTSCollections(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	id := r.URL.Query().Get("id")
+	workResult := ParseWork(CTSParams{Sourcetext: sourcetext})
+	catalog := completeCatalog(ParseCatalog(CTSParams{Sourcetext: sourcetext}), workResult)
+	result, ok := DTSCollectionFor(id, BuildTextInventory(catalog), catalog, workResult, dtsBase(r))
+	if !ok {
+		writeDTSError(w, http.StatusNotFound, "Unknown collection "+id)
+		return
+	}
+	writeDTS(w, result)
+}
+
+func ReturnDTSNavigation(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	query := r.URL.Query()
+	id := query.Get("id")
+	if isCTSURN(id) != true {
+		writeDTSError(w, http.StatusBadRequest, id+" is not valid CTS.")
+		return
+	}
+	level := 1
+	if query.Get("level") != "" {
+		requested, err := strconv.Atoi(query.Get("level"))
+		if err != nil || requested < 1 {
+			writeDTSError(w, http.StatusBadRequest, "Invalid level "+query.Get("level"))
+			return
+		}
+		level = requested
+	}
+	workResult := ParseWork(CTSParams{Sourcetext: sourcetext})
+	requestUrn := dtsPassageUrn(id, query.Get("ref"), query.Get("start"), query.Get("end"))
+	indices := ctsPassageIndices(workResult, requestUrn)
+	if len(indices) == 0 {
+		writeDTSError(w, http.StatusNotFound, "No results for "+requestUrn)
+		return
+	}
+	depth := referenceDepth(requestUrn) + level
+	if query.Get("start") != "" {
+		depth = referenceDepth(requestUrn) + level - 1
+	}
+	result := DTSNavigation{Context: dtsContext,
+		ID:        dtsBase(r) + "/navigation?" + r.URL.RawQuery,
+		CiteDepth: citeDepth(workResult, id),
+		Level:     depth,
+		Passage:   dtsBase(r) + "/document?id=" + url.QueryEscape(id) + "{&ref}{&start}{&end}",
+		Member:    []DTSRef{}}
+	var refs []string
+	for _, i := range indices {
+		if referenceDepth(workResult.URN[i]) < depth {
+			continue
+		}
+		ref := ctsReference(truncateReference(workResult.URN[i], depth))
+		if !contains(refs, ref) {
+			refs = append(refs, ref)
+			result.Member = append(result.Member, DTSRef{Ref: ref})
+		}
+	}
+	writeDTS(w, result)
+}
+
+func ReturnDTSDocument(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	query := r.URL.Query()
+	id := query.Get("id")
+	if isCTSURN(id) != true {
+		writeDTSError(w, http.StatusBadRequest, id+" is not valid CTS.")
+		return
+	}
+	workResult := ParseWork(CTSParams{Sourcetext: sourcetext})
+	requestUrn := dtsPassageUrn(id, query.Get("ref"), query.Get("start"), query.Get("end"))
+	indices := ctsPassageIndices(workResult, requestUrn)
+	if len(indices) == 0 {
+		writeDTSError(w, http.StatusNotFound, "No results for "+requestUrn)
+		return
+	}
+	w.Header().Set("Content-Type", "application/tei+xml; charset=utf-8")
+	w.Header().Set("Link", `<`+dtsBase(r)+`/navigation?id=`+url.QueryEscape(id)+`>; rel="contents", <`+dtsBase(r)+`/collections?id=`+url.QueryEscape(id)+`>; rel="collection"`)
+	var urns, texts []string
+	for _, i := range indices {
+		urns = append(urns, workResult.URN[i])
+		texts = append(texts, workResult.Text[i])
+	}
+	fmt.Fprint(w, TEIDocument(urns, texts, teiEntry(ParseCatalog(CTSParams{Sourcetext: sourcetext}), urns[0])))
+}
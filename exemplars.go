@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var tokenPattern = regexp.MustCompile(`[\p{L}\p{M}\p{N}'’]+|[^\s\p{L}\p{M}\p{N}]`)
+var wordPattern = regexp.MustCompile(`^[\p{L}\p{M}\p{N}'’]+$`)
+
+// tokenize splits a text node into word and punctuation tokens.
+func tokenize(s string) []string {
+	return tokenPattern.FindAllString(s, -1)
+}
+
+func isWordToken(s string) bool {
+	return wordPattern.MatchString(s)
+}
+
+// normalizeToken returns the form under which a word token is counted and indexed.
+func normalizeToken(s string) string {
+	return strings.ToLower(strings.Trim(s, "'’"))
+}
+
+// tokenizedExemplars derives one exemplar node per token for every version
+// configured in exemplars (version URN -> exemplar label). Token n of the node
+// urn:cts:ns:tg.wk.ver:1.1 becomes urn:cts:ns:tg.wk.ver.label:1.1.n.
+func tokenizedExemplars(work Work, exemplars map[string]string) Work {
+	var result Work
+	if len(exemplars) == 0 {
+		return result
+	}
+	labels := map[string]string{}
+	for version, label := range exemplars {
+		if isCTSURN(version) && len(strings.Split(version, ":")) > 3 {
+			labels[strings.Join(strings.Split(version, ":")[0:4], ":")] = label
+		}
+	}
+	for i := range work.URN {
+		if len(strings.Split(work.URN[i], ":")) != 5 {
+			continue
+		}
+		ctsurn := splitCTS(work.URN[i])
+		label, ok := labels[ctsurn.Stem]
+		if !ok || label == "" {
+			continue
+		}
+		tokens := tokenize(work.Text[i])
+		for j := range tokens {
+			result.URN = append(result.URN, ctsurn.Stem+"."+label+":"+ctsurn.Reference+"."+strconv.Itoa(j+1))
+			result.Text = append(result.Text, tokens[j])
+		}
+	}
+	return result
+}
+
+// tokenizedCatalog derives catalog entries for the tokenized exemplars of
+// the catalogued versions, adding a "token" level to the citation scheme.
+func tokenizedCatalog(catalog []CatalogEntry, exemplars map[string]string) []CatalogEntry {
+	var result []CatalogEntry
+	for version, label := range exemplars {
+		entry, ok := catalogEntryFor(catalog, version)
+		if !ok || label == "" || len(strings.Split(strings.Split(entry.URN, ":")[3], ".")) != 3 {
+			continue
+		}
+		entry.URN = ctsStem(entry.URN) + "." + label + ":"
+		entry.CitationScheme = entry.CitationScheme + ",token"
+		entry.ExemplarLabel = label
+		result = append(result, entry)
+	}
+	return result
+}
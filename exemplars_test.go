@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Μῆνιν ἄειδε θεὰ,", []string{"Μῆνιν", "ἄειδε", "θεὰ", ","}},
+		{"arma virumque cano.", []string{"arma", "virumque", "cano", "."}},
+		{"δ’ ἄρ' — 12", []string{"δ’", "ἄρ'", "—", "12"}},
+		{"a...b", []string{"a", ".", ".", ".", "b"}},
+	}
+	for _, test := range tests {
+		if got := tokenize(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("tokenize(%q) = %q; want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestIsWordToken(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"Μῆνιν", true},
+		{"δ’", true},
+		{"12", true},
+		{",", false},
+		{"—", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := isWordToken(test.token); got != test.want {
+			t.Errorf("isWordToken(%q) = %v; want %v", test.token, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeToken(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"Arma", "arma"},
+		{"Μῆνιν", "μῆνιν"},
+		{"δ’", "δ"},
+		{"'tis", "tis"},
+		{"can't", "can't"},
+	}
+	for _, test := range tests {
+		if got := normalizeToken(test.token); got != test.want {
+			t.Errorf("normalizeToken(%q) = %q; want %q", test.token, got, test.want)
+		}
+	}
+}
+
+func TestTokenizedExemplars(t *testing.T) {
+	work := Work{URN: []string{
+		"urn:cts:ns:tg.wk.ed:1.1",
+		"urn:cts:ns:tg.wk.ed2:1.1",
+	}, Text: []string{"arma virumque.", "cano"}}
+	got := tokenizedExemplars(work, map[string]string{"urn:cts:ns:tg.wk.ed:": "tokens"})
+	want := Work{URN: []string{
+		"urn:cts:ns:tg.wk.ed.tokens:1.1.1",
+		"urn:cts:ns:tg.wk.ed.tokens:1.1.2",
+		"urn:cts:ns:tg.wk.ed.tokens:1.1.3",
+	}, Text: []string{"arma", "virumque", "."}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenizedExemplars = %+v; want %+v", got, want)
+	}
+	if got := tokenizedExemplars(work, nil); len(got.URN) != 0 {
+		t.Errorf("tokenizedExemplars without configured versions = %+v; want none", got)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ObjectQuery is a property-value condition of /objects/find. An empty
+// Property matches the condition against every property of an object.
+type ObjectQuery struct {
+	Property string
+	Op       string
+	Value    string
+	pattern  *regexp.Regexp
+	number   float64
+}
+
+var findOps = []string{"eq", "ne", "contains", "regex", "lt", "le", "gt", "ge"}
+
+// objectQueryFor reads property, op and value from the request. A property
+// may be given by id ("rv") or URN; a property-extended request URN
+// (urn:cite2:hmt:msA.v1.rv:) supplies the property when none is given.
+func objectQueryFor(r *http.Request, collection CiteCollection, urn Cite2Urn) (ObjectQuery, error) {
+	query := r.URL.Query()
+	q := ObjectQuery{Property: query.Get("property"), Op: query.Get("op"), Value: query.Get("value")}
+	if q.Op == "" {
+		q.Op = "eq"
+	}
+	if !contains(findOps, q.Op) {
+		return q, errors.New("Unknown op " + q.Op + ". Use one of " + strings.Join(findOps, ", ") + ".")
+	}
+	if q.Property == "" && urn.Property != "" {
+		q.Property = urn.Property
+	}
+	if q.Property != "" {
+		if !strings.HasPrefix(q.Property, "urn:") {
+			q.Property = propertyURN(collection.URN, q.Property)
+		}
+		if _, ok := collectionProperty(collection, q.Property); !ok {
+			return q, errors.New("Collection " + collection.URN + " has no property " + q.Property + ".")
+		}
+	}
+	switch q.Op {
+	case "regex":
+		pattern, err := regexp.Compile(q.Value)
+		if err != nil {
+			return q, errors.New("Invalid regular expression " + q.Value + ".")
+		}
+		q.pattern = pattern
+	case "lt", "le", "gt", "ge":
+		number, err := strconv.ParseFloat(strings.TrimSpace(q.Value), 64)
+		if err != nil {
+			return q, errors.New("Numeric comparison " + q.Op + " needs a number, not " + q.Value + ".")
+		}
+		q.number = number
+	}
+	return q, nil
+}
+
+// collectionProperty returns the declared property of a collection.
+func collectionProperty(collection CiteCollection, property string) (CiteProperty, bool) {
+	for _, declared := range collection.Properties {
+		if declared.URN == property {
+			return declared, true
+		}
+	}
+	return CiteProperty{}, false
+}
+
+// matchesValue tests one property value against the query, comparing by the
+// value's type: numbers numerically, booleans as booleans and everything else
+// as strings. Ordering comparisons only match Number properties.
+func (q ObjectQuery) matchesValue(value CitePropertyValue) bool {
+	switch q.Op {
+	case "contains":
+		return strings.Contains(strings.ToLower(value.Raw), strings.ToLower(q.Value))
+	case "regex":
+		return q.pattern.MatchString(value.Raw)
+	case "lt", "le", "gt", "ge":
+		number, ok := value.Value.(float64)
+		if !ok {
+			return false
+		}
+		switch q.Op {
+		case "lt":
+			return number < q.number
+		case "le":
+			return number <= q.number
+		case "gt":
+			return number > q.number
+		default:
+			return number >= q.number
+		}
+	}
+	equal := strings.TrimSpace(value.Raw) == strings.TrimSpace(q.Value)
+	switch v := value.Value.(type) {
+	case float64:
+		if number, err := strconv.ParseFloat(strings.TrimSpace(q.Value), 64); err == nil {
+			equal = v == number
+		}
+	case bool:
+		if boolean, err := strconv.ParseBool(strings.TrimSpace(q.Value)); err == nil {
+			equal = v == boolean
+		}
+	}
+	if q.Op == "ne" {
+		return !equal
+	}
+	return equal
+}
+
+// Matches reports whether any value of the queried property satisfies the
+// query.
+func (q ObjectQuery) Matches(object CiteObject) bool {
+	for _, value := range object.Properties {
+		if q.Property != "" && value.Property != q.Property {
+			continue
+		}
+		if q.matchesValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindObjects returns the objects of collection that satisfy the query, in
+// the collection's order.
+func FindObjects(library CiteLibrary, collection CiteCollection, q ObjectQuery) []CiteObject {
+	var result []CiteObject
+	for _, object := range orderedObjects(library, collection.URN) {
+		if q.Matches(object) {
+			result = append(result, object)
+		}
+	}
+	return result
+}
+
+func ReturnFindObjects(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	urn, err := ParseCite2Urn(requestUrn)
+	var result ObjectsResponse
+	switch {
+	case err != nil:
+		result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+	default:
+		library := ParseCollections(CTSParams{Sourcetext: sourcetext})
+		collections := collectionsMatching(library, urn)
+		result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Success", Objects: []CiteObject{}}
+		if len(collections) == 0 {
+			result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: "No results for " + requestUrn}
+		}
+		for _, collection := range collections {
+			q, err := objectQueryFor(r, collection, urn)
+			if err != nil {
+				result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+				break
+			}
+			result.Objects = append(result.Objects, FindObjects(library, collection, q)...)
+		}
+	}
+	result.Service = "/objects/find"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
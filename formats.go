@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// passageFormat returns the output format requested with ?format= or, failing
+// that, with the Accept header: "json" (default), "text", "cex" or "tei".
+func passageFormat(r *http.Request) string {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "txt", "plain":
+		return "text"
+	case "":
+	default:
+		return format
+	}
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/json"):
+		return "json"
+	case strings.Contains(accept, "text/cex"), strings.Contains(accept, "application/cex"):
+		return "cex"
+	case strings.Contains(accept, "application/tei+xml"):
+		return "tei"
+	case strings.Contains(accept, "text/plain"):
+		return "text"
+	default:
+		return "json"
+	}
+}
+
+// writePassage writes a /texts response in the format the request asks for.
+// Unknown formats are rejected whether or not the passage exists.
+func writePassage(w http.ResponseWriter, r *http.Request, result NodeResponse) {
+	format := passageFormat(r)
+	switch format {
+	case "json", "text", "cex", "tei":
+	default:
+		http.Error(w, "Unknown format "+format+"; use json, text, cex or tei.", http.StatusBadRequest)
+		return
+	}
+	if result.Status != "Success" && format != "json" {
+		http.Error(w, result.Message, http.StatusNotFound)
+		return
+	}
+	switch format {
+	case "text":
+		withUrns := r.URL.Query().Get("urns") == "true"
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, node := range result.Nodes {
+			switch {
+			case withUrns:
+				fmt.Fprintln(w, strings.Join(node.URN, "")+"\t"+strings.Join(node.Text, ""))
+			default:
+				fmt.Fprintln(w, strings.Join(node.Text, ""))
+			}
+		}
+	case "cex":
+		w.Header().Set("Content-Type", "text/cex; charset=utf-8")
+		fmt.Fprint(w, passageCEX(result.Nodes, ParseCatalog(CTSParams{Sourcetext: requestSource(r)})))
+	case "tei":
+		var urns, texts []string
+		for _, node := range result.Nodes {
+			urns = append(urns, strings.Join(node.URN, ""))
+			texts = append(texts, strings.Join(node.Text, ""))
+		}
+		if len(urns) == 0 {
+			http.Error(w, "No passages to export.", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/tei+xml; charset=utf-8")
+		fmt.Fprint(w, TEIDocument(urns, texts, teiEntry(ParseCatalog(CTSParams{Sourcetext: requestSource(r)}), urns[0])))
+	default:
+		resultJSON, _ := json.Marshal(result)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintln(w, string(resultJSON))
+	}
+}
+
+// passageCEX serializes nodes as a CEX fragment with catalog rows for the
+// versions involved.
+func passageCEX(nodes []Node, catalog []CatalogEntry) string {
+	var versions []string
+	var rows []string
+	for _, node := range nodes {
+		urn := strings.Join(node.URN, "")
+		rows = append(rows, urn+"#"+strings.Join(node.Text, ""))
+		if !contains(versions, ctsStem(urn)) {
+			versions = append(versions, ctsStem(urn))
+		}
+	}
+	var cex strings.Builder
+	cex.WriteString("#!cexversion\n3.0\n\n")
+	var entries []string
+	for _, version := range versions {
+		if entry, ok := catalogEntryFor(catalog, version); ok {
+			entries = append(entries, entry.CEX())
+		}
+	}
+	if len(entries) > 0 {
+		cex.WriteString("#!ctscatalog\n" + catalogHeader + "\n")
+		cex.WriteString(strings.Join(entries, "\n") + "\n\n")
+	}
+	cex.WriteString("#!ctsdata\n")
+	for _, row := range rows {
+		cex.WriteString(row + "\n")
+	}
+	return cex.String()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPassageFormat(t *testing.T) {
+	tests := []struct {
+		query, accept string
+		want          string
+	}{
+		{"", "", "json"},
+		{"?format=txt", "", "text"},
+		{"?format=plain", "application/json", "text"},
+		{"?format=CEX", "", "cex"},
+		{"?format=xml", "", "xml"},
+		{"", "text/plain", "text"},
+		{"", "application/cex", "cex"},
+		{"", "application/tei+xml", "tei"},
+		{"", "application/json, text/plain", "json"},
+		{"", "image/png", "json"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/texts/urn:cts:ns:tg.wk.ed:1"+test.query, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if got := passageFormat(r); got != test.want {
+			t.Errorf("passageFormat(%q, %q) = %q; want %q", test.query, test.accept, got, test.want)
+		}
+	}
+}
+
+func TestWritePassageStatus(t *testing.T) {
+	found := NodeResponse{Status: "Success", Nodes: []Node{{URN: []string{"urn:cts:ns:tg.wk.ed:1"}, Text: []string{"arma"}}}}
+	missing := NodeResponse{Status: "Exception", Message: "No results"}
+	empty := NodeResponse{Status: "Success"}
+	tests := []struct {
+		format string
+		result NodeResponse
+		want   int
+	}{
+		{"json", found, 200},
+		{"json", missing, 200},
+		{"text", found, 200},
+		{"text", missing, 404},
+		{"tei", empty, 404},
+		{"xml", found, 400},
+		{"xml", missing, 400},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		writePassage(w, httptest.NewRequest("GET", "/texts/urn:cts:ns:tg.wk.ed:1?format="+test.format, nil), test.result)
+		if w.Code != test.want {
+			t.Errorf("writePassage(%q, %s) answered %d; want %d", test.format, test.result.Status, w.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// maxGraphDepth limits /relations/graph traversals.
+const maxGraphDepth = 5
+
+type GraphNode struct {
+	URN  string `json:"urn"`
+	Type string `json:"type"`
+	Hops int    `json:"hops"`
+}
+
+type GraphResponse struct {
+	RequestUrn []string       `json:"requestUrn"`
+	Status     string         `json:"status"`
+	Service    string         `json:"service"`
+	Message    string         `json:"message,omitempty"`
+	Depth      int            `json:"depth"`
+	Nodes      []GraphNode    `json:"nodes"`
+	Edges      []CiteRelation `json:"edges"`
+}
+
+func urnType(s string) string {
+	if isCTSURN(s) {
+		return "CtsUrn"
+	}
+	return "Cite2Urn"
+}
+
+// RelationGraph walks up to depth hops from start. Every hop follows the
+// relations whose subject or object a node of the previous hop contains, in
+// either direction, and adds the URNs at their other end as new nodes.
+func RelationGraph(relations []CiteRelation, work Work, start string, depth int) ([]GraphNode, []CiteRelation) {
+	nodes := []GraphNode{{URN: start, Type: urnType(start), Hops: 0}}
+	edges := []CiteRelation{}
+	seen := map[string]bool{start: true}
+	used := map[CiteRelation]bool{}
+	frontier := []string{start}
+	for hop := 1; hop <= depth && len(frontier) > 0; hop++ {
+		var next []string
+		for _, urn := range frontier {
+			for _, relation := range RelationsFor(relations, work, urn) {
+				if !used[relation] {
+					used[relation] = true
+					edges = append(edges, relation)
+				}
+				for _, end := range []string{relation.Subject, relation.Object} {
+					if !seen[end] {
+						seen[end] = true
+						nodes = append(nodes, GraphNode{URN: end, Type: urnType(end), Hops: hop})
+						next = append(next, end)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+	return nodes, edges
+}
+
+func ReturnRelationGraph(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	var result GraphResponse
+	depth := 1
+	if r.URL.Query().Get("depth") != "" {
+		requested, err := strconv.Atoi(r.URL.Query().Get("depth"))
+		if err != nil || requested < 1 || requested > maxGraphDepth {
+			depth = -1
+		} else {
+			depth = requested
+		}
+	}
+	_, err := ParseCite2Urn(requestUrn)
+	switch {
+	case isCTSURN(requestUrn) != true && err != nil:
+		message := requestUrn + " is neither a CTS nor a CITE2 URN."
+		result = GraphResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	case depth < 0:
+		message := "depth must be a number from 1 to " + strconv.Itoa(maxGraphDepth) + "."
+		result = GraphResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		relations := FilterRelations(ParseRelations(CTSParams{Sourcetext: sourcetext}), verbFilterFor(r))
+		nodes, edges := RelationGraph(relations, ParseWork(CTSParams{Sourcetext: sourcetext}), requestUrn, depth)
+		switch {
+		case len(edges) == 0:
+			message := "No results for " + requestUrn
+			result = GraphResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = GraphResponse{RequestUrn: []string{requestUrn}, Status: "Success", Depth: depth, Nodes: nodes, Edges: edges}
+		}
+	}
+	result.Service = "/relations/graph"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
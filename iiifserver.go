@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// The built-in IIIF Image API serves the files under iiif_image_directory.
+// The image urn:cite2:hmt:vaimg.2017a:VA012RN_0013 is read from
+// hmt/vaimg/2017a/VA012RN_0013.jpg (or .jpeg, .png) below that directory.
+
+// Limits on the size of images the built-in service renders. They are
+// advertised in info.json; larger requests are rejected.
+const (
+	maxImageWidth  = 10000
+	maxImageHeight = 10000
+	maxImageArea   = 25000000
+)
+
+type IIIFImageInfo struct {
+	Context        string   `json:"@context"`
+	ID             string   `json:"id"`
+	Type           string   `json:"type"`
+	Protocol       string   `json:"protocol"`
+	Profile        string   `json:"profile"`
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	MaxWidth       int      `json:"maxWidth"`
+	MaxHeight      int      `json:"maxHeight"`
+	MaxArea        int      `json:"maxArea"`
+	ExtraQualities []string `json:"extraQualities"`
+	ExtraFormats   []string `json:"extraFormats"`
+	ExtraFeatures  []string `json:"extraFeatures"`
+}
+
+var imageExtensions = []string{".jpg", ".jpeg", ".png"}
+
+// imageFile returns the path of the file of an image URN. Object IDs that
+// could leave the collection's directory are rejected.
+func imageFile(directory string, s string) (string, error) {
+	urn, err := ParseCite2Urn(s)
+	if err != nil {
+		return "", err
+	}
+	if urn.Object == "" || urn.Version == "" || urn.Extension != "" {
+		return "", errors.New(s + " does not name a single image.")
+	}
+	if strings.ContainsAny(urn.Object, `/\`) || strings.Contains(urn.Object, "..") {
+		return "", errors.New(s + " is not a valid image name.")
+	}
+	for _, extension := range imageExtensions {
+		path := filepath.Join(directory, urn.Namespace, urn.Collection, urn.Version, urn.Object+extension)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("No image file for " + s + ".")
+}
+
+// iiifRegion resolves the region parameter against the image bounds.
+func iiifRegion(region string, bounds image.Rectangle) (image.Rectangle, error) {
+	w, h := bounds.Dx(), bounds.Dy()
+	var result image.Rectangle
+	switch {
+	case region == "full":
+		return bounds, nil
+	case region == "square":
+		side := w
+		if h < side {
+			side = h
+		}
+		result = image.Rect((w-side)/2, (h-side)/2, (w-side)/2+side, (h-side)/2+side)
+	default:
+		percent := strings.HasPrefix(region, "pct:")
+		parts := strings.Split(strings.TrimPrefix(region, "pct:"), ",")
+		if len(parts) != 4 {
+			return result, errors.New("Invalid region " + region + ".")
+		}
+		var values [4]float64
+		for i := range parts {
+			value, err := strconv.ParseFloat(parts[i], 64)
+			if err != nil || value < 0 {
+				return result, errors.New("Invalid region " + region + ".")
+			}
+			values[i] = value
+		}
+		if percent {
+			values[0], values[2] = values[0]*float64(w)/100, values[2]*float64(w)/100
+			values[1], values[3] = values[1]*float64(h)/100, values[3]*float64(h)/100
+		}
+		x, y := int(math.Round(values[0])), int(math.Round(values[1]))
+		result = image.Rect(x, y, x+int(math.Round(values[2])), y+int(math.Round(values[3])))
+	}
+	result = result.Add(bounds.Min).Intersect(bounds)
+	if result.Empty() {
+		return result, errors.New("Region " + region + " lies outside the image.")
+	}
+	return result, nil
+}
+
+// iiifSize resolves the size parameter for a region of width w and height h.
+// Sizes beyond the region need the "^" prefix; max is scaled down to the
+// size limits and explicit sizes beyond them are rejected.
+func iiifSize(size string, w int, h int) (int, int, error) {
+	upscale := strings.HasPrefix(size, "^")
+	size = strings.TrimPrefix(size, "^")
+	invalid := errors.New("Invalid size " + size + ".")
+	var width, height int
+	switch {
+	case size == "max" || size == "full":
+		scale := math.Min(1, math.Min(float64(maxImageWidth)/float64(w), float64(maxImageHeight)/float64(h)))
+		scale = math.Min(scale, math.Sqrt(float64(maxImageArea)/(float64(w)*float64(h))))
+		width, height = int(float64(w)*scale), int(float64(h)*scale)
+	case strings.HasPrefix(size, "pct:"):
+		n, err := strconv.ParseFloat(strings.TrimPrefix(size, "pct:"), 64)
+		if err != nil || n <= 0 || n > 100*maxImageWidth {
+			return 0, 0, invalid
+		}
+		width, height = int(math.Round(float64(w)*n/100)), int(math.Round(float64(h)*n/100))
+	default:
+		confined := strings.HasPrefix(size, "!")
+		parts := strings.Split(strings.TrimPrefix(size, "!"), ",")
+		if len(parts) != 2 || (parts[0] == "" && parts[1] == "") {
+			return 0, 0, invalid
+		}
+		var err error
+		if parts[0] != "" {
+			if width, err = strconv.Atoi(parts[0]); err != nil || width <= 0 {
+				return 0, 0, invalid
+			}
+		}
+		if parts[1] != "" {
+			if height, err = strconv.Atoi(parts[1]); err != nil || height <= 0 {
+				return 0, 0, invalid
+			}
+		}
+		switch {
+		case confined && (width == 0 || height == 0):
+			return 0, 0, invalid
+		case confined:
+			scale := math.Min(float64(width)/float64(w), float64(height)/float64(h))
+			width, height = int(math.Round(float64(w)*scale)), int(math.Round(float64(h)*scale))
+		case height == 0:
+			height = int(math.Round(float64(h) * float64(width) / float64(w)))
+		case width == 0:
+			width = int(math.Round(float64(w) * float64(height) / float64(h)))
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	if width > maxImageWidth || height > maxImageHeight || width*height > maxImageArea {
+		return 0, 0, errors.New("Size " + size + " exceeds the limits of " + strconv.Itoa(maxImageWidth) + " by " + strconv.Itoa(maxImageHeight) + " pixels and " + strconv.Itoa(maxImageArea) + " pixels in total.")
+	}
+	if !upscale && (width > w || height > h) {
+		return 0, 0, errors.New("Size " + size + " is larger than the region; use ^" + size + ".")
+	}
+	return width, height, nil
+}
+
+// scaleImage resizes the region of src to width by height by nearest-neighbour
+// sampling.
+func scaleImage(src image.Image, region image.Rectangle, width int, height int) *image.RGBA {
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := region.Min.Y + y*region.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := region.Min.X + x*region.Dx()/width
+			result.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return result
+}
+
+// rotateImage mirrors (if requested) and then rotates clockwise by a multiple
+// of 90 degrees.
+func rotateImage(src *image.RGBA, rotation string) (*image.RGBA, error) {
+	mirror := strings.HasPrefix(rotation, "!")
+	degrees, err := strconv.ParseFloat(strings.TrimPrefix(rotation, "!"), 64)
+	switch {
+	case err != nil || degrees < 0 || degrees > 360:
+		return nil, errors.New("Invalid rotation " + rotation + ".")
+	case math.Mod(degrees, 90) != 0:
+		return nil, errors.New("Only rotations by multiples of 90 degrees are supported.")
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if mirror {
+		mirrored := image.NewRGBA(src.Bounds())
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				mirrored.Set(w-1-x, y, src.At(x, y))
+			}
+		}
+		src = mirrored
+	}
+	turns := int(degrees/90) % 4
+	for i := 0; i < turns; i++ {
+		w, h = src.Bounds().Dx(), src.Bounds().Dy()
+		rotated := image.NewRGBA(image.Rect(0, 0, h, w))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				rotated.Set(h-1-y, x, src.At(x, y))
+			}
+		}
+		src = rotated
+	}
+	return src, nil
+}
+
+// applyQuality converts an image to the requested quality.
+func applyQuality(src image.Image, quality string) (image.Image, error) {
+	switch quality {
+	case "default", "color":
+		return src, nil
+	case "gray", "bitonal":
+		gray := image.NewGray(src.Bounds())
+		for y := src.Bounds().Min.Y; y < src.Bounds().Max.Y; y++ {
+			for x := src.Bounds().Min.X; x < src.Bounds().Max.X; x++ {
+				value := color.GrayModel.Convert(src.At(x, y)).(color.Gray)
+				if quality == "bitonal" {
+					if value.Y < 128 {
+						value.Y = 0
+					} else {
+						value.Y = 255
+					}
+				}
+				gray.SetGray(x, y, value)
+			}
+		}
+		return gray, nil
+	default:
+		return nil, errors.New("Invalid quality " + quality + ".")
+	}
+}
+
+// localImage checks that the built-in image service is enabled and serves
+// the collection of the requested image, and returns the image's file, or
+// writes the error response.
+func localImage(w http.ResponseWriter, r *http.Request) (string, bool) {
+	config := LoadConfiguration("config.json")
+	if config.ImageDirectory == "" {
+		http.Error(w, "The built-in IIIF image service is not enabled.", http.StatusNotFound)
+		return "", false
+	}
+	if urn, err := ParseCite2Urn(mux.Vars(r)["URN"]); err == nil {
+		if base, ok := config.IIIFImages[urn.CollectionURN()]; ok {
+			http.Error(w, "Images of "+urn.CollectionURN()+" are served by "+base+".", http.StatusNotFound)
+			return "", false
+		}
+	}
+	path, err := imageFile(config.ImageDirectory, mux.Vars(r)["URN"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return "", false
+	}
+	return path, true
+}
+
+func ReturnImageBase(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, r.URL.Path+"/info.json", http.StatusSeeOther)
+}
+
+func ReturnImageInfo(w http.ResponseWriter, r *http.Request) {
+	path, ok := localImage(w, r)
+	if !ok {
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		http.Error(w, "Cannot read "+mux.Vars(r)["URN"]+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	urn, _ := ParseCite2Urn(mux.Vars(r)["URN"])
+	service, _ := imageService(urn, LoadConfiguration("config.json"), serverBase(r))
+	result := IIIFImageInfo{Context: "http://iiif.io/api/image/3/context.json",
+		ID:             service.ID,
+		Type:           "ImageService3",
+		Protocol:       "http://iiif.io/api/image",
+		Profile:        "level2",
+		Width:          config.Width,
+		Height:         config.Height,
+		MaxWidth:       maxImageWidth,
+		MaxHeight:      maxImageHeight,
+		MaxArea:        maxImageArea,
+		ExtraQualities: []string{"color", "gray", "bitonal"},
+		ExtraFormats:   []string{"png"},
+		ExtraFeatures:  []string{"mirroring", "regionSquare", "sizeUpscaling"}}
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", `application/ld+json;profile="http://iiif.io/api/image/3/context.json"`)
+	fmt.Fprintln(w, string(resultJSON))
+}
+
+func ReturnImageRequest(w http.ResponseWriter, r *http.Request) {
+	path, ok := localImage(w, r)
+	if !ok {
+		return
+	}
+	vars := mux.Vars(r)
+	quality, format := vars["quality"], ""
+	if i := strings.LastIndex(quality, "."); i >= 0 {
+		quality, format = quality[:i], quality[i+1:]
+	}
+	if format != "jpg" && format != "png" {
+		http.Error(w, "Unsupported format "+format+".", http.StatusBadRequest)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	src, _, err := image.Decode(file)
+	if err != nil {
+		http.Error(w, "Cannot read "+vars["URN"]+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	region, err := iiifRegion(vars["region"], src.Bounds())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	width, height, err := iiifSize(vars["size"], region.Dx(), region.Dy())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rotated, err := rotateImage(scaleImage(src, region, width, height), vars["rotation"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := applyQuality(rotated, quality)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format == "png" {
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, result)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	jpeg.Encode(w, result, &jpeg.Options{Quality: 90})
+}
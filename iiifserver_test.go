@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImageFile(t *testing.T) {
+	directory := t.TempDir()
+	os.MkdirAll(filepath.Join(directory, "hmt", "vaimg", "2017a"), 0755)
+	os.WriteFile(filepath.Join(directory, "hmt", "vaimg", "2017a", "VA012RN_0013.png"), []byte{}, 0644)
+	os.WriteFile(filepath.Join(directory, "secret.png"), []byte{}, 0644)
+	tests := []struct {
+		urn  string
+		want string
+		ok   bool
+	}{
+		{"urn:cite2:hmt:vaimg.2017a:VA012RN_0013", filepath.Join(directory, "hmt", "vaimg", "2017a", "VA012RN_0013.png"), true},
+		{"urn:cite2:hmt:vaimg.2017a:VA012RN_0014", "", false},
+		{"urn:cite2:hmt:vaimg.2017a:", "", false},
+		{"urn:cite2:hmt:vaimg.2017a:VA012RN_0013@0.1,0.1,0.2,0.2", "", false},
+		{"urn:cite2:hmt:vaimg.2017a:../../../secret", "", false},
+		{"urn:cite2:hmt:vaimg.2017a:..", "", false},
+		{`urn:cite2:hmt:vaimg.2017a:a\b`, "", false},
+	}
+	for _, test := range tests {
+		got, err := imageFile(directory, test.urn)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("imageFile(%q) = %q, %v; want %q", test.urn, got, err, test.want)
+		}
+	}
+}
+
+func TestIiifRegion(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 200)
+	tests := []struct {
+		region string
+		want   image.Rectangle
+		ok     bool
+	}{
+		{"full", bounds, true},
+		{"square", image.Rect(100, 0, 300, 200), true},
+		{"10,20,30,40", image.Rect(10, 20, 40, 60), true},
+		{"pct:10,10,50,50", image.Rect(40, 20, 240, 120), true},
+		{"350,150,100,100", image.Rect(350, 150, 400, 200), true},
+		{"500,0,10,10", image.Rectangle{}, false},
+		{"10,20,30", image.Rectangle{}, false},
+		{"10,20,-30,40", image.Rectangle{}, false},
+		{"a,b,c,d", image.Rectangle{}, false},
+	}
+	for _, test := range tests {
+		got, err := iiifRegion(test.region, bounds)
+		if (err == nil) != test.ok || (test.ok && got != test.want) {
+			t.Errorf("iiifRegion(%q) = %v, %v; want %v", test.region, got, err, test.want)
+		}
+	}
+	if got, _ := iiifRegion("square", image.Rect(0, 0, 100, 300)); got != image.Rect(0, 100, 100, 200) {
+		t.Errorf("iiifRegion(square) of a portrait image = %v", got)
+	}
+}
+
+func TestIiifSize(t *testing.T) {
+	tests := []struct {
+		size          string
+		w, h          int
+		width, height int
+		ok            bool
+	}{
+		{"max", 400, 200, 400, 200, true},
+		{"^max", 400, 200, 400, 200, true},
+		{"max", 20000, 10000, 7071, 3535, true},
+		{"200,", 400, 200, 200, 100, true},
+		{",50", 400, 200, 100, 50, true},
+		{"100,100", 400, 200, 100, 100, true},
+		{"!100,100", 400, 200, 100, 50, true},
+		{"pct:50", 400, 200, 200, 100, true},
+		{"800,", 400, 200, 0, 0, false},
+		{"^800,", 400, 200, 800, 400, true},
+		{"^pct:200", 400, 200, 800, 400, true},
+		{"^10001,", 400, 200, 0, 0, false},
+		{"^9000,9000", 400, 200, 0, 0, false},
+		{"^pct:9223372036854775807", 400, 200, 0, 0, false},
+		{"^9223372036854775807,", 400, 200, 0, 0, false},
+		{",", 400, 200, 0, 0, false},
+		{"!100,", 400, 200, 0, 0, false},
+		{"0,10", 400, 200, 0, 0, false},
+		{"pct:0", 400, 200, 0, 0, false},
+		{"big", 400, 200, 0, 0, false},
+	}
+	for _, test := range tests {
+		width, height, err := iiifSize(test.size, test.w, test.h)
+		if (err == nil) != test.ok || width != test.width || height != test.height {
+			t.Errorf("iiifSize(%q, %d, %d) = %d, %d, %v; want %d, %d", test.size, test.w, test.h, width, height, err, test.width, test.height)
+		}
+	}
+}
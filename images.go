@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ImageROI is a region of interest given as fractions of the image width
+// and height: @left,top,width,height.
+type ImageROI struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// IIIFImage describes a CITE image and the IIIF Image API URLs of the
+// full image and of its region of interest.
+type IIIFImage struct {
+	URN        string       `json:"urn"`
+	Collection string       `json:"collection"`
+	Image      string       `json:"image"`
+	ROI        *ImageROI    `json:"roi,omitempty"`
+	Service    *IIIFService `json:"service,omitempty"`
+	Info       string       `json:"info,omitempty"`
+	Full       string       `json:"full,omitempty"`
+	Region     string       `json:"region,omitempty"`
+}
+
+type ImageResponse struct {
+	RequestUrn []string   `json:"requestUrn"`
+	Status     string     `json:"status"`
+	Service    string     `json:"service"`
+	Message    string     `json:"message,omitempty"`
+	Image      *IIIFImage `json:"image,omitempty"`
+}
+
+// ParseROI parses the extension of an image URN. All four values must lie
+// between 0 and 1 and the region must not reach beyond the image.
+func ParseROI(s string) (ImageROI, error) {
+	var roi ImageROI
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return roi, errors.New("Region of interest " + s + " must be left,top,width,height.")
+	}
+	var values [4]float64
+	for i := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(parts[i]), 64)
+		if err != nil || value < 0 || value > 1 {
+			return roi, errors.New("Region of interest " + s + " must consist of numbers from 0 to 1.")
+		}
+		values[i] = value
+	}
+	roi = ImageROI{Left: values[0], Top: values[1], Width: values[2], Height: values[3]}
+	if roi.Width == 0 || roi.Height == 0 || roi.Left+roi.Width > 1.0001 || roi.Top+roi.Height > 1.0001 {
+		return roi, errors.New("Region of interest " + s + " is empty or reaches beyond the image.")
+	}
+	return roi, nil
+}
+
+// percent formats a fraction as a IIIF percentage without float noise.
+func percent(f float64) string {
+	return strconv.FormatFloat(f*100, 'f', -1, 32)
+}
+
+// IIIFRegion returns the IIIF Image API region parameter of the ROI.
+func (roi ImageROI) IIIFRegion() string {
+	return "pct:" + percent(roi.Left) + "," + percent(roi.Top) + "," + percent(roi.Width) + "," + percent(roi.Height)
+}
+
+// imageService returns the IIIF Image API service of an image: the
+// iiif_images base of its collection, with the type and profile given in
+// iiif_image_services (default ImageService3, level0), or, if the collection
+// has none and iiif_image_directory is set, the built-in service at server.
+func imageService(urn Cite2Urn, config ServerConfig, server string) (IIIFService, bool) {
+	if base, ok := config.IIIFImages[urn.CollectionURN()]; ok {
+		service := IIIFService{ID: strings.TrimSuffix(base, "/") + "/" + urn.Object, Type: "ImageService3", Profile: "level0"}
+		if configured, ok := config.IIIFServices[urn.CollectionURN()]; ok {
+			if configured.Type != "" {
+				service.Type = configured.Type
+			}
+			if configured.Profile != "" {
+				service.Profile = configured.Profile
+			}
+		}
+		return service, true
+	}
+	if config.ImageDirectory != "" {
+		return IIIFService{ID: server + "/iiif/image/" + urn.ObjectURN(), Type: "ImageService3", Profile: "level2"}, true
+	}
+	return IIIFService{}, false
+}
+
+// ImageFor parses an image URN and adds IIIF URLs if an image service is
+// configured for its collection. Only single objects name an image; server is
+// the root URL of the built-in image service.
+func ImageFor(s string, config ServerConfig, server string) (IIIFImage, error) {
+	var result IIIFImage
+	urn, err := ParseCite2Urn(s)
+	if err != nil {
+		return result, err
+	}
+	if urn.Object == "" {
+		return result, errors.New(s + " does not name a single image.")
+	}
+	result = IIIFImage{URN: s, Collection: urn.CollectionURN(), Image: urn.Object}
+	if urn.Extension != "" {
+		roi, err := ParseROI(urn.Extension)
+		if err != nil {
+			return result, err
+		}
+		result.ROI = &roi
+	}
+	service, ok := imageService(urn, config, server)
+	if !ok {
+		return result, nil
+	}
+	base := service.ID
+	result.Service = &service
+	result.Info = base + "/info.json"
+	result.Full = base + "/full/max/0/default.jpg"
+	if result.ROI != nil {
+		result.Region = base + "/" + result.ROI.IIIFRegion() + "/max/0/default.jpg"
+	}
+	return result, nil
+}
+
+func ReturnImage(w http.ResponseWriter, r *http.Request) {
+	requestUrn := mux.Vars(r)["URN"]
+	var result ImageResponse
+	image, err := ImageFor(requestUrn, LoadConfiguration("config.json"), serverBase(r))
+	switch {
+	case err != nil:
+		result = ImageResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+	case image.Info == "":
+		message := "No IIIF service configured for " + image.Collection
+		result = ImageResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message, Image: &image}
+	default:
+		result = ImageResponse{RequestUrn: []string{requestUrn}, Status: "Success", Image: &image}
+	}
+	result.Service = "/image"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
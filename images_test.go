@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestImageService(t *testing.T) {
+	config := ServerConfig{
+		IIIFImages: map[string]string{
+			"urn:cite2:hmt:vaimg.2017a:": "https://example.org/iiif/vaimg/",
+			"urn:cite2:hmt:vbimg.2017a:": "https://example.org/iiif/vbimg",
+		},
+		IIIFServices: map[string]IIIFService{
+			"urn:cite2:hmt:vbimg.2017a:": {Type: "ImageService2", Profile: "level2"},
+		},
+		ImageDirectory: "images",
+	}
+	tests := []struct {
+		urn  string
+		want string
+	}{
+		{"urn:cite2:hmt:vaimg.2017a:VA012RN_0013", `{"id":"https://example.org/iiif/vaimg/VA012RN_0013","type":"ImageService3","profile":"level0"}`},
+		{"urn:cite2:hmt:vbimg.2017a:VB012RN_0013", `{"@id":"https://example.org/iiif/vbimg/VB012RN_0013","@type":"ImageService2","profile":"level2"}`},
+		{"urn:cite2:hmt:local.v1:img1", `{"id":"http://localhost/iiif/image/urn:cite2:hmt:local.v1:img1","type":"ImageService3","profile":"level2"}`},
+	}
+	for _, test := range tests {
+		urn, _ := ParseCite2Urn(test.urn)
+		service, ok := imageService(urn, config, "http://localhost")
+		got, _ := json.Marshal(service)
+		if !ok || string(got) != test.want {
+			t.Errorf("imageService(%q) = %s; want %s", test.urn, got, test.want)
+		}
+	}
+	config.ImageDirectory = ""
+	urn, _ := ParseCite2Urn("urn:cite2:hmt:local.v1:img1")
+	if _, ok := imageService(urn, config, "http://localhost"); ok {
+		t.Errorf("imageService found a service for an unconfigured collection")
+	}
+}
+
+func TestParseROI(t *testing.T) {
+	tests := []struct {
+		roi    string
+		want   ImageROI
+		region string
+		ok     bool
+	}{
+		{"0.1,0.2,0.3,0.4", ImageROI{Left: 0.1, Top: 0.2, Width: 0.3, Height: 0.4}, "pct:10,20,30,40", true},
+		{"0, 0, 1, 1", ImageROI{Width: 1, Height: 1}, "pct:0,0,100,100", true},
+		{"0.0525,0.1,0.25,0.05", ImageROI{Left: 0.0525, Top: 0.1, Width: 0.25, Height: 0.05}, "pct:5.25,10,25,5", true},
+		{"0.1,0.2,0.3", ImageROI{}, "", false},
+		{"0.1,0.2,0.3,x", ImageROI{}, "", false},
+		{"0.1,0.2,1.3,0.4", ImageROI{}, "", false},
+		{"-0.1,0.2,0.3,0.4", ImageROI{}, "", false},
+		{"0.8,0.2,0.3,0.4", ImageROI{}, "", false},
+		{"0.1,0.2,0,0.4", ImageROI{}, "", false},
+	}
+	for _, test := range tests {
+		got, err := ParseROI(test.roi)
+		if (err == nil) != test.ok {
+			t.Errorf("ParseROI(%q) error = %v", test.roi, err)
+			continue
+		}
+		if test.ok && (got != test.want || got.IIIFRegion() != test.region) {
+			t.Errorf("ParseROI(%q) = %+v, %s; want %+v, %s", test.roi, got, got.IIIFRegion(), test.want, test.region)
+		}
+	}
+}
+
+func TestImageFor(t *testing.T) {
+	config := ServerConfig{IIIFImages: map[string]string{"urn:cite2:hmt:vaimg.2017a:": "https://example.org/iiif/vaimg"}}
+	image, err := ImageFor("urn:cite2:hmt:vaimg.2017a:VA012RN_0013@0.1,0.2,0.3,0.4", config, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Full != "https://example.org/iiif/vaimg/VA012RN_0013/full/max/0/default.jpg" ||
+		image.Region != "https://example.org/iiif/vaimg/VA012RN_0013/pct:10,20,30,40/max/0/default.jpg" ||
+		image.Info != "https://example.org/iiif/vaimg/VA012RN_0013/info.json" {
+		t.Errorf("ImageFor = %+v", image)
+	}
+	image, err = ImageFor("urn:cite2:hmt:other.v1:img", config, "")
+	if err != nil || image.Info != "" {
+		t.Errorf("ImageFor without a service = %+v, %v", image, err)
+	}
+	for _, urn := range []string{"urn:cite2:hmt:vaimg.2017a:", "urn:cite2:hmt:vaimg.2017a:a-b", "urn:cite2:hmt:vaimg.2017a:a@0.1"} {
+		if _, err := ImageFor(urn, config, ""); err == nil {
+			t.Errorf("ImageFor(%q) accepted", urn)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Canvas size used when iiif_canvas_width and iiif_canvas_height are not
+// configured. The manifest does not fetch info.json to learn image sizes.
+const (
+	defaultCanvasWidth  = 2000
+	defaultCanvasHeight = 3000
+)
+
+type IIIFService struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Profile string `json:"profile"`
+}
+
+// MarshalJSON writes Image API 2 services with the @id and @type keys their
+// clients expect.
+func (s IIIFService) MarshalJSON() ([]byte, error) {
+	if s.Type == "ImageService2" {
+		return json.Marshal(map[string]string{"@id": s.ID, "@type": s.Type, "profile": s.Profile})
+	}
+	type service IIIFService
+	return json.Marshal(service(s))
+}
+
+type IIIFBody struct {
+	ID       string        `json:"id,omitempty"`
+	Type     string        `json:"type"`
+	Format   string        `json:"format"`
+	Value    string        `json:"value,omitempty"`
+	Language string        `json:"language,omitempty"`
+	Width    int           `json:"width,omitempty"`
+	Height   int           `json:"height,omitempty"`
+	Service  []IIIFService `json:"service,omitempty"`
+}
+
+type IIIFAnnotation struct {
+	ID         string              `json:"id"`
+	Type       string              `json:"type"`
+	Motivation string              `json:"motivation"`
+	Label      map[string][]string `json:"label,omitempty"`
+	Body       IIIFBody            `json:"body"`
+	Target     string              `json:"target"`
+}
+
+type IIIFAnnotationPage struct {
+	ID    string           `json:"id"`
+	Type  string           `json:"type"`
+	Items []IIIFAnnotation `json:"items"`
+}
+
+type IIIFCanvas struct {
+	ID          string               `json:"id"`
+	Type        string               `json:"type"`
+	Label       map[string][]string  `json:"label"`
+	Width       int                  `json:"width"`
+	Height      int                  `json:"height"`
+	Items       []IIIFAnnotationPage `json:"items"`
+	Annotations []IIIFAnnotationPage `json:"annotations,omitempty"`
+}
+
+type IIIFManifest struct {
+	Context string              `json:"@context"`
+	ID      string              `json:"id"`
+	Type    string              `json:"type"`
+	Label   map[string][]string `json:"label"`
+	Items   []IIIFCanvas        `json:"items"`
+}
+
+func iiifLabel(s string) map[string][]string {
+	return map[string][]string{"none": {s}}
+}
+
+// serverBase returns the scheme and host the request was made to.
+func serverBase(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// iiifBase returns the absolute URL prefix of the IIIF resources for the
+// requested CEX.
+func iiifBase(r *http.Request) string {
+	base := serverBase(r)
+	if requestCEX := mux.Vars(r)["CEX"]; requestCEX != "" {
+		base = base + "/" + requestCEX
+	}
+	return base + "/iiif"
+}
+
+// surfaceImage returns the default image of a surface: the first
+// Cite2Urn-typed property naming an image with an image service, otherwise
+// the image of the first DSE record on the surface.
+func surfaceImage(surface CiteObject, records []DSERecord, config ServerConfig, server string) (IIIFImage, bool) {
+	for _, value := range surface.Properties {
+		if value.Type != "Cite2Urn" || value.Raw == surface.URN {
+			continue
+		}
+		if image, err := ImageFor(value.Raw, config, server); err == nil && image.Info != "" {
+			return image, true
+		}
+	}
+	for _, record := range records {
+		if record.Surface != surface.URN {
+			continue
+		}
+		if urn, err := ParseCite2Urn(record.ImageROI); err == nil {
+			if image, err := ImageFor(urn.ObjectURN(), config, server); err == nil && image.Info != "" {
+				return image, true
+			}
+		}
+	}
+	return IIIFImage{}, false
+}
+
+// roiFragment converts an image region of interest to a canvas fragment.
+func roiFragment(roi ImageROI, width int, height int) string {
+	x := int(roi.Left * float64(width))
+	y := int(roi.Top * float64(height))
+	w := int(roi.Width * float64(width))
+	h := int(roi.Height * float64(height))
+	return "#xywh=" + strconv.Itoa(x) + "," + strconv.Itoa(y) + "," + strconv.Itoa(w) + "," + strconv.Itoa(h)
+}
+
+// SurfaceManifest builds a IIIF Presentation 3 manifest with one canvas per
+// surface of collection, in the collection's order. Every canvas is painted
+// with the surface's default image and annotated with the text of the DSE
+// passages on the surface, targeting their regions of interest.
+func SurfaceManifest(collection CiteCollection, surfaces []CiteObject, records []DSERecord, work Work, catalog []CatalogEntry, config ServerConfig, server string, base string) IIIFManifest {
+	width, height := config.CanvasWidth, config.CanvasHeight
+	if width <= 0 || height <= 0 {
+		width, height = defaultCanvasWidth, defaultCanvasHeight
+	}
+	texts := map[string]string{}
+	for i := range work.URN {
+		texts[work.URN[i]] = work.Text[i]
+	}
+	manifestID := base + "/manifest/" + url.PathEscape(collection.URN)
+	result := IIIFManifest{Context: "http://iiif.io/api/presentation/3/context.json",
+		ID:    manifestID,
+		Type:  "Manifest",
+		Label: iiifLabel(collection.Description),
+		Items: []IIIFCanvas{}}
+	for _, surface := range surfaces {
+		canvasID := base + "/canvas/" + url.PathEscape(surface.URN)
+		label := surface.Label
+		if label == "" {
+			label = surface.URN
+		}
+		canvas := IIIFCanvas{ID: canvasID, Type: "Canvas", Label: iiifLabel(label), Width: width, Height: height, Items: []IIIFAnnotationPage{}}
+		if image, ok := surfaceImage(surface, records, config, server); ok {
+			painting := IIIFAnnotation{ID: canvasID + "/painting", Type: "Annotation", Motivation: "painting",
+				Body: IIIFBody{ID: image.Full, Type: "Image", Format: "image/jpeg", Width: width, Height: height,
+					Service: []IIIFService{*image.Service}},
+				Target: canvasID}
+			canvas.Items = append(canvas.Items, IIIFAnnotationPage{ID: canvasID + "/page", Type: "AnnotationPage", Items: []IIIFAnnotation{painting}})
+		}
+		page := IIIFAnnotationPage{ID: canvasID + "/passages", Type: "AnnotationPage"}
+		for _, record := range records {
+			if record.Surface != surface.URN {
+				continue
+			}
+			target := canvasID
+			if urn, err := ParseCite2Urn(record.ImageROI); err == nil && urn.Extension != "" {
+				if roi, err := ParseROI(urn.Extension); err == nil {
+					target = canvasID + roiFragment(roi, width, height)
+				}
+			}
+			body := IIIFBody{Type: "TextualBody", Format: "text/plain", Value: record.Passage}
+			if text, ok := texts[record.Passage]; ok {
+				body.Value = text
+			}
+			if entry, ok := catalogEntryFor(catalog, record.Passage); ok {
+				body.Language = entry.Language
+			}
+			page.Items = append(page.Items, IIIFAnnotation{ID: canvasID + "/passages/" + url.PathEscape(record.URN),
+				Type:       "Annotation",
+				Motivation: "supplementing",
+				Label:      iiifLabel(record.Passage),
+				Body:       body,
+				Target:     target})
+		}
+		if len(page.Items) > 0 {
+			canvas.Annotations = []IIIFAnnotationPage{page}
+		}
+		result.Items = append(result.Items, canvas)
+	}
+	return result
+}
+
+func ReturnManifest(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	var result ObjectsResponse
+	status := http.StatusNotFound
+	urn, err := ParseCite2Urn(requestUrn)
+	switch {
+	case err != nil:
+		status = http.StatusBadRequest
+		result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+	case !urn.IsCollection():
+		status = http.StatusBadRequest
+		message := "A manifest is built from a collection of surfaces, not from " + requestUrn + "."
+		result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		library := ParseCollections(CTSParams{Sourcetext: sourcetext})
+		collections := collectionsMatching(library, urn)
+		if len(collections) > 0 {
+			collection := collections[0]
+			records := ParseDSE(CTSParams{Sourcetext: sourcetext}, serverBase(r))
+			var work Work
+			var catalog []CatalogEntry
+			if len(records) > 0 {
+				work = ParseWork(CTSParams{Sourcetext: sourcetext})
+				catalog = ParseCatalog(CTSParams{Sourcetext: sourcetext})
+			}
+			manifest := SurfaceManifest(collection,
+				orderedObjects(library, collection.URN),
+				records,
+				work,
+				catalog,
+				LoadConfiguration("config.json"),
+				serverBase(r),
+				iiifBase(r))
+			resultJSON, _ := json.Marshal(manifest)
+			w.Header().Set("Content-Type", `application/ld+json;profile="http://iiif.io/api/presentation/3/context.json"`)
+			fmt.Fprintln(w, string(resultJSON))
+			return
+		}
+		message := "No results for " + requestUrn
+		result = ObjectsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	}
+	result.Service = "/iiif/manifest"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, string(resultJSON))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type ObjectNode struct {
+	Object   CiteObject `json:"object"`
+	Previous []string   `json:"previous"`
+	Next     []string   `json:"next"`
+	Index    int        `json:"sequence"`
+}
+
+type ObjectNodeResponse struct {
+	RequestUrn []string     `json:"requestUrn"`
+	Status     string       `json:"status"`
+	Service    string       `json:"service"`
+	Message    string       `json:"message,omitempty"`
+	Nodes      []ObjectNode `json:"objects"`
+}
+
+// objectNode wraps the object at index i of an ordered collection with the
+// URNs of its neighbours.
+func objectNode(objects []CiteObject, i int) ObjectNode {
+	node := ObjectNode{Object: objects[i], Previous: []string{}, Next: []string{}, Index: i + 1}
+	if i > 0 {
+		node.Previous = []string{objects[i-1].URN}
+	}
+	if i < len(objects)-1 {
+		node.Next = []string{objects[i+1].URN}
+	}
+	return node
+}
+
+// returnObjectNavigation answers /objects/first, /last, /previous and /next.
+// The collection must declare an ordering property; step picks the object to
+// return from the ordered objects and the position of the requested object
+// (-1 if the request names only the collection).
+func returnObjectNavigation(w http.ResponseWriter, r *http.Request, service string, step func(objects []CiteObject, position int) int) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	urn, err := ParseCite2Urn(requestUrn)
+	var result ObjectNodeResponse
+	switch {
+	case err != nil:
+		result = ObjectNodeResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: err.Error()}
+	case urn.IsRange():
+		message := "Navigation needs a single object or a collection, not the range " + requestUrn + "."
+		result = ObjectNodeResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		library := ParseCollections(CTSParams{Sourcetext: sourcetext})
+		collections := collectionsMatching(library, urn)
+		switch {
+		case len(collections) == 0:
+			message := "No results for " + requestUrn
+			result = ObjectNodeResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		case collections[0].OrderingProperty == "":
+			message := collections[0].URN + " is not an ordered collection."
+			result = ObjectNodeResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			objects := orderedObjects(library, collections[0].URN)
+			position := -1
+			for i := range objects {
+				if !urn.IsCollection() && strings.Split(objects[i].URN, ":")[4] == urn.Object {
+					position = i
+				}
+			}
+			switch {
+			case len(objects) == 0:
+				message := "No objects in " + collections[0].URN
+				result = ObjectNodeResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+			case !urn.IsCollection() && position < 0:
+				message := "Could not find object " + requestUrn + " in source."
+				result = ObjectNodeResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+			default:
+				result = ObjectNodeResponse{RequestUrn: []string{requestUrn}, Status: "Success", Nodes: []ObjectNode{}}
+				if i := step(objects, position); i >= 0 && i < len(objects) {
+					result.Nodes = []ObjectNode{objectNode(objects, i)}
+				}
+			}
+		}
+	}
+	result.Service = service
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
+
+func ReturnFirstObject(w http.ResponseWriter, r *http.Request) {
+	returnObjectNavigation(w, r, "/objects/first", func(objects []CiteObject, position int) int {
+		return 0
+	})
+}
+
+func ReturnLastObject(w http.ResponseWriter, r *http.Request) {
+	returnObjectNavigation(w, r, "/objects/last", func(objects []CiteObject, position int) int {
+		return len(objects) - 1
+	})
+}
+
+func ReturnPrevObject(w http.ResponseWriter, r *http.Request) {
+	returnObjectNavigation(w, r, "/objects/previous", func(objects []CiteObject, position int) int {
+		if position < 0 {
+			return -1
+		}
+		return position - 1
+	})
+}
+
+func ReturnNextObject(w http.ResponseWriter, r *http.Request) {
+	returnObjectNavigation(w, r, "/objects/next", func(objects []CiteObject, position int) int {
+		if position < 0 {
+			return -1
+		}
+		return position + 1
+	})
+}
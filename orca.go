@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ORCAAlignment aligns a passage (usually with a subreference) with an
+// analysis, the analytical text that stands for the passage in the analysis,
+// and the node of the analytical exemplar holding that text.
+type ORCAAlignment struct {
+	URN      string `json:"urn"`
+	Label    string `json:"label"`
+	Passage  string `json:"passage"`
+	Analysis string `json:"analysis"`
+	Text     string `json:"text"`
+	Exemplar string `json:"exemplar"`
+}
+
+type ORCAResponse struct {
+	RequestUrn []string        `json:"requestUrn"`
+	Status     string          `json:"status"`
+	Service    string          `json:"service"`
+	Message    string          `json:"message,omitempty"`
+	Alignments []ORCAAlignment `json:"alignments"`
+}
+
+// ParseORCA reads the alignments of every collection #!datamodels declares
+// to implement the ORCA model.
+func ParseORCA(p CTSParams) []ORCAAlignment {
+	data, err := getContent(p.Sourcetext)
+	if err != nil {
+		return nil
+	}
+	return orcaAlignments(string(data))
+}
+
+// orcaAlignments takes passage, analysis, text and exemplar from the citedata
+// of the ORCA collections of a CEX source. The analytical text may also be
+// called deformation.
+func orcaAlignments(str string) []ORCAAlignment {
+	var result []ORCAAlignment
+	models := parseDataModels(str)
+	if len(modelCollections(models, orcaModel)) == 0 {
+		return result
+	}
+	library := parseCollections(str)
+	for _, collection := range modelCollections(models, orcaModel) {
+		for _, object := range collectionObjects(library, collection) {
+			alignment := ORCAAlignment{URN: object.URN,
+				Label:    object.Label,
+				Passage:  propertyRaw(object, collection, "passage"),
+				Analysis: propertyRaw(object, collection, "analysis"),
+				Text:     propertyRaw(object, collection, "text"),
+				Exemplar: propertyRaw(object, collection, "exemplar")}
+			if alignment.Text == "" {
+				alignment.Text = propertyRaw(object, collection, "deformation")
+			}
+			result = append(result, alignment)
+		}
+	}
+	return result
+}
+
+// orcaExemplars reconstructs the analytical exemplars as text nodes. Nodes
+// follow the order of the passages they analyse in work, then source order.
+func orcaExemplars(alignments []ORCAAlignment, work Work) Work {
+	var result Work
+	position := map[string]int{}
+	for i := range work.URN {
+		if _, ok := position[work.URN[i]]; !ok {
+			position[work.URN[i]] = i
+		}
+	}
+	var nodes []ORCAAlignment
+	for _, alignment := range alignments {
+		if isCTSURN(alignment.Exemplar) && ctsReference(alignment.Exemplar) != "" {
+			nodes = append(nodes, alignment)
+		}
+	}
+	passageIndex := func(a ORCAAlignment) int {
+		if i, ok := position[ctsStem(a.Passage)+":"+ctsReference(a.Passage)]; ok {
+			return i
+		}
+		return len(work.URN)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return passageIndex(nodes[i]) < passageIndex(nodes[j])
+	})
+	for _, node := range nodes {
+		result.URN = append(result.URN, node.Exemplar)
+		result.Text = append(result.Text, node.Text)
+	}
+	return result
+}
+
+// orcaCatalog derives catalog entries for the analytical exemplars from the
+// entries of the versions they analyse.
+func orcaCatalog(catalog []CatalogEntry, alignments []ORCAAlignment) []CatalogEntry {
+	var result []CatalogEntry
+	var seen []string
+	for _, alignment := range alignments {
+		stem := ctsStem(alignment.Exemplar)
+		work := strings.Split(strings.Split(stem+":", ":")[3], ".")
+		if !isCTSURN(alignment.Exemplar) || len(work) != 4 || contains(seen, stem) {
+			continue
+		}
+		seen = append(seen, stem)
+		entry, ok := catalogEntryFor(catalog, strings.TrimSuffix(stem, "."+work[3])+":")
+		if !ok {
+			continue
+		}
+		entry.URN = stem + ":"
+		if referenceDepth(alignment.Exemplar) > len(citationLevels(entry.CitationScheme)) {
+			entry.CitationScheme = entry.CitationScheme + ",analysis"
+		}
+		entry.ExemplarLabel = work[3]
+		result = append(result, entry)
+	}
+	return result
+}
+
+// alignmentMatches reports whether a CTS request contains the passage or the
+// exemplar node of an alignment, or a CITE2 request contains the alignment
+// or its analysis.
+func alignmentMatches(request string, alignment ORCAAlignment) bool {
+	if isCTSURN(request) {
+		return relationContains(request, alignment.Passage) || relationContains(request, alignment.Exemplar)
+	}
+	return relationContains(request, alignment.URN) || relationContains(request, alignment.Analysis)
+}
+
+func ReturnORCA(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	var result ORCAResponse
+	_, err := ParseCite2Urn(requestUrn)
+	switch {
+	case isCTSURN(requestUrn) != true && err != nil:
+		message := requestUrn + " is neither a CTS nor a CITE2 URN."
+		result = ORCAResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		var alignments []ORCAAlignment
+		for _, alignment := range ParseORCA(CTSParams{Sourcetext: sourcetext}) {
+			if alignmentMatches(requestUrn, alignment) {
+				alignments = append(alignments, alignment)
+			}
+		}
+		switch {
+		case len(alignments) == 0:
+			message := "No results for " + requestUrn
+			result = ORCAResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = ORCAResponse{RequestUrn: []string{requestUrn}, Status: "Success", Alignments: alignments}
+		}
+	}
+	result.Service = "/orca"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type CiteRelation struct {
+	Subject string `json:"subject"`
+	Verb    string `json:"verb"`
+	Object  string `json:"object"`
+}
+
+type RelationsResponse struct {
+	RequestUrn []string       `json:"requestUrn"`
+	Status     string         `json:"status"`
+	Service    string         `json:"service"`
+	Message    string         `json:"message,omitempty"`
+	Relations  []CiteRelation `json:"relations"`
+}
+
+// ParseRelations reads the subject#verb#object triples of every #!relations
+// block. Lines whose subject is not a URN (headers) are skipped.
+func ParseRelations(p CTSParams) []CiteRelation {
+	data, err := getContent(p.Sourcetext)
+	if err != nil {
+		return nil
+	}
+	return parseRelations(string(data))
+}
+
+func parseRelations(str string) []CiteRelation {
+	var result []CiteRelation
+	for _, block := range cexBlocks(str, "relations") {
+		for _, line := range cexRecords(block) {
+			if len(line) < 3 || !strings.HasPrefix(line[0], "urn:") || !isCite2URN(line[1]) {
+				continue
+			}
+			result = append(result, CiteRelation{Subject: line[0], Verb: line[1], Object: line[2]})
+		}
+	}
+	return result
+}
+
+// ctsRangeEnds returns the URNs of the first and last passage of a CTS range,
+// or the URN itself if it is not a range.
+func ctsRangeEnds(s string) []string {
+	parts := strings.Split(s, ":")
+	if len(parts) < 5 || !strings.Contains(parts[4], "-") {
+		return []string{s}
+	}
+	var result []string
+	for _, end := range strings.Split(parts[4], "-") {
+		result = append(result, ctsStem(s)+":"+end)
+	}
+	return result
+}
+
+// cite2Contains reports whether the request URN contains a CITE2 URN. A
+// collection contains its objects, a version-less URN every version and an
+// object without extension every extension of itself. Ranges match on their
+// end points.
+func cite2Contains(request Cite2Urn, s string) bool {
+	node, err := ParseCite2Urn(s)
+	if err != nil || node.Namespace != request.Namespace || node.Collection != request.Collection {
+		return false
+	}
+	if request.Version != "" && request.Version != node.Version {
+		return false
+	}
+	if request.IsCollection() {
+		return true
+	}
+	requested := [][2]string{{request.Object, request.Extension}}
+	if request.IsRange() {
+		requested = [][2]string{{request.RangeBegin, request.BeginExtension}, {request.RangeEnd, request.EndExtension}}
+	}
+	found := [][2]string{{node.Object, node.Extension}}
+	if node.IsRange() {
+		found = [][2]string{{node.RangeBegin, node.BeginExtension}, {node.RangeEnd, node.EndExtension}}
+	}
+	for _, r := range requested {
+		for _, n := range found {
+			if r[0] == n[0] && (r[1] == "" || r[1] == n[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// relationContains reports whether the request URN, CTS or CITE2, contains
+// one side of a relation. CTS requests contain every passage further down
+// the citation hierarchy; ranges match on their end points (see passageScope
+// for ranges resolved against the corpus).
+func relationContains(request string, s string) bool {
+	if isCTSURN(request) {
+		for _, r := range ctsRangeEnds(request) {
+			for _, n := range ctsRangeEnds(s) {
+				if urnContains(r, n) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	urn, err := ParseCite2Urn(request)
+	return err == nil && cite2Contains(urn, s)
+}
+
+// verbFilterFor reads the comma-separated verb query parameter. Verbs may
+// be given as CITE2 URNs or by their object id ("commentsOn").
+func verbFilterFor(r *http.Request) []string {
+	var result []string
+	for _, verb := range strings.Split(r.URL.Query().Get("verb"), ",") {
+		if strings.TrimSpace(verb) != "" {
+			result = append(result, strings.TrimSpace(verb))
+		}
+	}
+	return result
+}
+
+// verbMatches reports whether a relation's verb is one of verbs. An empty
+// filter matches every verb.
+func verbMatches(verbs []string, verb string) bool {
+	if len(verbs) == 0 {
+		return true
+	}
+	for _, v := range verbs {
+		if v == verb || (!strings.HasPrefix(v, "urn:") && strings.HasSuffix(verb, ":"+v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRelations returns the relations whose verb matches the filter.
+func FilterRelations(relations []CiteRelation, verbs []string) []CiteRelation {
+	var result []CiteRelation
+	for _, relation := range relations {
+		if verbMatches(verbs, relation.Verb) {
+			result = append(result, relation)
+		}
+	}
+	return result
+}
+
+// passageScope returns a test for whether a relation side lies within the
+// request. A CTS range is resolved to the nodes of the corpus in document
+// order, as scopeIndices does, and contains every passage with a node in it;
+// passages outside the corpus fall back to relationContains.
+func passageScope(work Work, request string) func(string) bool {
+	if !isCTSURN(request) || !isRange(request) {
+		return func(s string) bool {
+			return relationContains(request, s)
+		}
+	}
+	inRange := map[string]bool{}
+	for _, i := range explicitScopeIndices(work, request) {
+		inRange[work.URN[i]] = true
+	}
+	return func(s string) bool {
+		if relationContains(request, s) {
+			return true
+		}
+		if isCTSURN(s) != true {
+			return false
+		}
+		for _, i := range explicitScopeIndices(work, s) {
+			if inRange[work.URN[i]] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RelationsFor returns the relations in which the request URN contains the
+// subject or the object. work resolves CTS ranges.
+func RelationsFor(relations []CiteRelation, work Work, request string) []CiteRelation {
+	var result []CiteRelation
+	within := passageScope(work, request)
+	for _, relation := range relations {
+		if within(relation.Subject) || within(relation.Object) {
+			result = append(result, relation)
+		}
+	}
+	return result
+}
+
+func ReturnRelations(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	var result RelationsResponse
+	_, err := ParseCite2Urn(requestUrn)
+	switch {
+	case isCTSURN(requestUrn) != true && err != nil:
+		message := requestUrn + " is neither a CTS nor a CITE2 URN."
+		result = RelationsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		var work Work
+		if isCTSURN(requestUrn) && isRange(requestUrn) {
+			work = ParseWork(CTSParams{Sourcetext: sourcetext})
+		}
+		relations := RelationsFor(FilterRelations(ParseRelations(CTSParams{Sourcetext: sourcetext}), verbFilterFor(r)), work, requestUrn)
+		switch {
+		case len(relations) == 0:
+			message := "No results for " + requestUrn
+			result = RelationsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = RelationsResponse{RequestUrn: []string{requestUrn}, Status: "Success", Relations: relations}
+		}
+	}
+	result.Service = "/relations"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
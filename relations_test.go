@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestRelationContains(t *testing.T) {
+	tests := []struct {
+		request, s string
+		want       bool
+	}{
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:ns:tg.wk.ed:1.1", true},
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:ns:tg.wk.ed:1.1@μῆνιν", true},
+		{"urn:cts:ns:tg.wk.ed:1.1", "urn:cts:ns:tg.wk.ed:1", false},
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cts:ns:tg.wk.ed:2.1-1.3", true},
+		{"urn:cts:ns:tg.wk.ed:1-2", "urn:cts:ns:tg.wk.ed:2.4", true},
+		{"urn:cts:ns:tg.wk:1", "urn:cts:ns:tg.wk.tr:1.1", true},
+		{"urn:cts:ns:tg.wk.ed:1", "urn:cite2:hmt:msA.v1:12r", false},
+		{"urn:cite2:hmt:msA.v1:", "urn:cite2:hmt:msA.v1:12r", true},
+		{"urn:cite2:hmt:msA:", "urn:cite2:hmt:msA.v2:12r", true},
+		{"urn:cite2:hmt:msA.v1:", "urn:cite2:hmt:msA.v2:12r", false},
+		{"urn:cite2:hmt:msA.v1:12r", "urn:cite2:hmt:msA.v1:12v", false},
+		{"urn:cite2:hmt:img.v1:a", "urn:cite2:hmt:img.v1:a@0.1,0.1,0.2,0.2", true},
+		{"urn:cite2:hmt:img.v1:a@0.1,0.1,0.2,0.2", "urn:cite2:hmt:img.v1:a", false},
+		{"urn:cite2:hmt:msA.v1:12r-13v", "urn:cite2:hmt:msA.v1:13v", true},
+		{"urn:cite2:hmt:msA.v1:12r", "urn:cts:ns:tg.wk.ed:1", false},
+		{"not a urn", "urn:cts:ns:tg.wk.ed:1", false},
+	}
+	for _, test := range tests {
+		if got := relationContains(test.request, test.s); got != test.want {
+			t.Errorf("relationContains(%q, %q) = %v; want %v", test.request, test.s, got, test.want)
+		}
+	}
+}
+
+func TestRelationsForRange(t *testing.T) {
+	work := Work{URN: scopeURNs, Text: make([]string, len(scopeURNs))}
+	relations := []CiteRelation{
+		{Subject: "urn:cts:ns:tg.wk.ed:1.1", Verb: "v", Object: "a"},
+		{Subject: "urn:cts:ns:tg.wk.ed:2.1-2.2", Verb: "v", Object: "b"},
+		{Subject: "urn:cts:ns:tg.wk.ed:2.2@x", Verb: "v", Object: "c"},
+		{Subject: "urn:cts:ns:tg.wk.ed:3.1", Verb: "v", Object: "d"},
+		{Subject: "urn:cts:ns:tg.wk.tr:2.1", Verb: "v", Object: "e"},
+		{Subject: "urn:cite2:hmt:msA.v1:12r", Verb: "v", Object: "f"},
+	}
+	tests := []struct {
+		request string
+		want    string
+	}{
+		{"urn:cts:ns:tg.wk.ed:1-3", "abcd"},
+		{"urn:cts:ns:tg.wk.ed:1.2-2.1", "b"},
+		{"urn:cts:ns:tg.wk.ed:2.2-3.1", "bcd"},
+		{"urn:cts:ns:tg.wk.ed:2", "bc"},
+		{"urn:cts:ns:tg.wk:2.1-2.1", "be"},
+	}
+	for _, test := range tests {
+		got := ""
+		for _, relation := range RelationsFor(relations, work, test.request) {
+			got += relation.Object
+		}
+		if got != test.want {
+			t.Errorf("RelationsFor(%q) = %q; want %q", test.request, got, test.want)
+		}
+	}
+}
+
+func TestVerbMatches(t *testing.T) {
+	tests := []struct {
+		verbs []string
+		verb  string
+		want  bool
+	}{
+		{nil, "urn:cite2:cite:verbs.v1:commentsOn", true},
+		{[]string{"commentsOn"}, "urn:cite2:cite:verbs.v1:commentsOn", true},
+		{[]string{"urn:cite2:cite:verbs.v1:commentsOn"}, "urn:cite2:cite:verbs.v1:commentsOn", true},
+		{[]string{"appearsOn", "commentsOn"}, "urn:cite2:cite:verbs.v1:commentsOn", true},
+		{[]string{"On"}, "urn:cite2:cite:verbs.v1:commentsOn", false},
+		{[]string{"urn:cite2:cite:verbs.v2:commentsOn"}, "urn:cite2:cite:verbs.v1:commentsOn", false},
+		{[]string{"illustrates"}, "urn:cite2:cite:verbs.v1:commentsOn", false},
+	}
+	for _, test := range tests {
+		if got := verbMatches(test.verbs, test.verb); got != test.want {
+			t.Errorf("verbMatches(%q, %q) = %v; want %v", test.verbs, test.verb, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+type TextStats struct {
+	URN            string `json:"urn"`
+	Nodes          int    `json:"nodes"`
+	Tokens         int    `json:"tokens"`
+	Characters     int    `json:"characters"`
+	DistinctWords  int    `json:"distinctWords"`
+	CitationDepth  int    `json:"citationDepth"`
+	FirstReference string `json:"firstReference"`
+	LastReference  string `json:"lastReference"`
+}
+
+type StatsResponse struct {
+	RequestUrn []string    `json:"requestUrn"`
+	Status     string      `json:"status"`
+	Service    string      `json:"service"`
+	Message    string      `json:"message,omitempty"`
+	Stats      []TextStats `json:"stats"`
+}
+
+// statsTTL is how long a parsed corpus is reused before its source is read again.
+const statsTTL = 5 * time.Minute
+
+type cachedCorpus struct {
+	work   Work
+	loaded time.Time
+}
+
+// statsCache holds one parsed corpus per CEX source; statistics are computed
+// from it for each request.
+var statsCache = struct {
+	sync.Mutex
+	corpora map[string]cachedCorpus
+}{corpora: map[string]cachedCorpus{}}
+
+// statsCorpus returns the corpus of sourcetext, reading the source again once
+// the cached copy is older than statsTTL. Sources without text are not cached.
+func statsCorpus(sourcetext string) Work {
+	statsCache.Lock()
+	for source, corpus := range statsCache.corpora {
+		if time.Since(corpus.loaded) > statsTTL {
+			delete(statsCache.corpora, source)
+		}
+	}
+	corpus, ok := statsCache.corpora[sourcetext]
+	statsCache.Unlock()
+	if ok {
+		return corpus.work
+	}
+	work := ParseWork(CTSParams{Sourcetext: sourcetext})
+	if len(work.URN) > 0 {
+		statsCache.Lock()
+		statsCache.corpora[sourcetext] = cachedCorpus{work: work, loaded: time.Now()}
+		statsCache.Unlock()
+	}
+	return work
+}
+
+// CorpusStats computes statistics for every version with nodes in the scope
+// of requestUrn; an empty requestUrn covers the whole corpus. Derived exemplars
+// only count when requestUrn names them.
+func CorpusStats(work Work, requestUrn string) []TextStats {
+	var indices []int
+	switch {
+	case requestUrn == "":
+		for i := range work.URN {
+			if !derivedNode(work, i) {
+				indices = append(indices, i)
+			}
+		}
+	default:
+		indices = explicitScopeIndices(work, requestUrn)
+	}
+	var result []TextStats
+	position := map[string]int{}
+	words := map[string]map[string]bool{}
+	for _, i := range indices {
+		stem := ctsStem(work.URN[i])
+		j, ok := position[stem]
+		if !ok {
+			j = len(result)
+			position[stem] = j
+			words[stem] = map[string]bool{}
+			result = append(result, TextStats{URN: stem + ":", FirstReference: ctsReference(work.URN[i])})
+		}
+		stats := &result[j]
+		stats.Nodes++
+		stats.Characters += utf8.RuneCountInString(work.Text[i])
+		for _, token := range tokenize(work.Text[i]) {
+			if isWordToken(token) {
+				stats.Tokens++
+				words[stem][normalizeToken(token)] = true
+			}
+		}
+		reference := ctsReference(work.URN[i])
+		if depth := len(strings.Split(reference, ".")); reference != "" && depth > stats.CitationDepth {
+			stats.CitationDepth = depth
+		}
+		stats.LastReference = reference
+	}
+	for i := range result {
+		result[i].DistinctWords = len(words[strings.TrimSuffix(result[i].URN, ":")])
+	}
+	return result
+}
+
+func ReturnStats(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	var result StatsResponse
+	switch {
+	case requestUrn != "" && isCTSURN(requestUrn) != true:
+		message := requestUrn + " is not valid CTS."
+		result = StatsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		stats := CorpusStats(statsCorpus(sourcetext), requestUrn)
+		switch {
+		case len(stats) == 0:
+			message := "No results for " + requestUrn
+			result = StatsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			result = StatsResponse{RequestUrn: []string{requestUrn}, Status: "Success", Stats: stats}
+		}
+	}
+	if requestUrn == "" {
+		result.RequestUrn = []string{}
+	}
+	result.Service = "/texts/stats"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// citationLevels splits a catalog citation scheme such as "book,line".
+func citationLevels(scheme string) []string {
+	var result []string
+	for _, level := range strings.Split(scheme, ",") {
+		if strings.TrimSpace(level) != "" {
+			result = append(result, strings.TrimSpace(level))
+		}
+	}
+	return result
+}
+
+// teiTextparts reconstructs the citation hierarchy of the nodes as nested
+// <div type="textpart"> elements; the text of every node goes into an <ab>.
+func teiTextparts(urns []string, texts []string, levels []string) string {
+	var tei strings.Builder
+	var open []string
+	for i := range urns {
+		ref := strings.Split(ctsReference(urns[i]), ".")
+		common := 0
+		for common < len(open) && common < len(ref)-1 && open[common] == ref[common] {
+			common++
+		}
+		for j := len(open); j > common; j-- {
+			tei.WriteString(`</div>`)
+		}
+		open = open[:common]
+		for j := common; j < len(ref); j++ {
+			tei.WriteString(`<div type="textpart"`)
+			if j < len(levels) {
+				tei.WriteString(` subtype="` + xmlEscape(levels[j]) + `"`)
+			}
+			tei.WriteString(` n="` + xmlEscape(ref[j]) + `">`)
+			if j < len(ref)-1 {
+				open = append(open, ref[j])
+			}
+		}
+		tei.WriteString(`<ab>` + xmlEscape(texts[i]) + `</ab></div>`)
+	}
+	for range open {
+		tei.WriteString(`</div>`)
+	}
+	return tei.String()
+}
+
+// teiRefsDecl declares how CTS references resolve to the textpart divs.
+func teiRefsDecl(levels []string) string {
+	var tei strings.Builder
+	tei.WriteString(`<refsDecl n="CTS">`)
+	for depth := len(levels); depth > 0; depth-- {
+		var match, xpath []string
+		for j := 0; j < depth; j++ {
+			match = append(match, `(\w+)`)
+			xpath = append(xpath, `tei:div[@n='$`+strconv.Itoa(j+1)+`']`)
+		}
+		tei.WriteString(`<cRefPattern n="` + xmlEscape(levels[depth-1]) + `" matchPattern="` + strings.Join(match, `\.`) + `" replacementPattern="#xpath(/tei:TEI/tei:text/tei:body/tei:div/` + strings.Join(xpath, "/") + `)"/>`)
+	}
+	tei.WriteString(`</refsDecl>`)
+	return tei.String()
+}
+
+// TEIDocument builds a TEI document for nodes of one version, with a
+// teiHeader taken from the version's #!ctscatalog entry.
+func TEIDocument(urns []string, texts []string, entry CatalogEntry) string {
+	levels := citationLevels(entry.CitationScheme)
+	edition := entry.VersionLabel
+	if entry.ExemplarLabel != "" {
+		edition = edition + ", " + entry.ExemplarLabel
+	}
+	var tei strings.Builder
+	tei.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	tei.WriteString(`<TEI xmlns="` + teiNamespace + `">`)
+	tei.WriteString(`<teiHeader><fileDesc>`)
+	tei.WriteString(`<titleStmt><title>` + xmlEscape(entry.WorkTitle) + `</title><author>` + xmlEscape(entry.GroupName) + `</author></titleStmt>`)
+	tei.WriteString(`<publicationStmt><idno type="CTS">` + xmlEscape(entry.URN) + `</idno>`)
+	if entry.Online {
+		tei.WriteString(`<availability status="free"><p>Online</p></availability>`)
+	}
+	tei.WriteString(`</publicationStmt>`)
+	tei.WriteString(`<sourceDesc><bibl><author>` + xmlEscape(entry.GroupName) + `</author><title>` + xmlEscape(entry.WorkTitle) + `</title><edition>` + xmlEscape(edition) + `</edition></bibl></sourceDesc>`)
+	tei.WriteString(`</fileDesc>`)
+	if len(levels) > 0 {
+		tei.WriteString(`<encodingDesc>` + teiRefsDecl(levels) + `</encodingDesc>`)
+	}
+	if entry.Language != "" {
+		tei.WriteString(`<profileDesc><langUsage><language ident="` + xmlEscape(entry.Language) + `"/></langUsage></profileDesc>`)
+	}
+	tei.WriteString(`</teiHeader>`)
+	tei.WriteString(`<text`)
+	if entry.Language != "" {
+		tei.WriteString(` xml:lang="` + xmlEscape(entry.Language) + `"`)
+	}
+	tei.WriteString(`><body><div type="edition" n="` + xmlEscape(entry.URN) + `">`)
+	tei.WriteString(teiTextparts(urns, texts, levels))
+	tei.WriteString(`</div></body></text></TEI>` + "\n")
+	return tei.String()
+}
+
+// teiEntry returns the catalog entry used for the TEI header of urn, or a
+// minimal one if the version is not catalogued.
+func teiEntry(catalog []CatalogEntry, urn string) CatalogEntry {
+	entry, ok := catalogEntryFor(catalog, urn)
+	if !ok {
+		stem := ctsStem(urn)
+		entry = CatalogEntry{URN: stem + ":", GroupName: stem, WorkTitle: stem, VersionLabel: stem}
+	}
+	return entry
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCitationLevels(t *testing.T) {
+	tests := []struct {
+		scheme string
+		want   []string
+	}{
+		{"book,line", []string{"book", "line"}},
+		{"line", []string{"line"}},
+		{" book, ,line ", []string{"book", "line"}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if got := citationLevels(test.scheme); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("citationLevels(%q) = %q; want %q", test.scheme, got, test.want)
+		}
+	}
+}
+
+func TestTeiTextparts(t *testing.T) {
+	tests := []struct {
+		urns, texts, levels []string
+		want                string
+	}{
+		{
+			[]string{"urn:cts:ns:tg.wk.ed:1"},
+			[]string{"arma"},
+			[]string{"line"},
+			`<div type="textpart" subtype="line" n="1"><ab>arma</ab></div>`,
+		},
+		{
+			[]string{"urn:cts:ns:tg.wk.ed:1.1", "urn:cts:ns:tg.wk.ed:1.2", "urn:cts:ns:tg.wk.ed:2.1"},
+			[]string{"a", "b & c", "d"},
+			[]string{"book", "line"},
+			`<div type="textpart" subtype="book" n="1">` +
+				`<div type="textpart" subtype="line" n="1"><ab>a</ab></div>` +
+				`<div type="textpart" subtype="line" n="2"><ab>b &amp; c</ab></div></div>` +
+				`<div type="textpart" subtype="book" n="2">` +
+				`<div type="textpart" subtype="line" n="1"><ab>d</ab></div></div>`,
+		},
+		{
+			[]string{"urn:cts:ns:tg.wk.ed:1.1.1"},
+			[]string{"a"},
+			[]string{"book", "line"},
+			`<div type="textpart" subtype="book" n="1"><div type="textpart" subtype="line" n="1">` +
+				`<div type="textpart" n="1"><ab>a</ab></div></div></div>`,
+		},
+	}
+	for _, test := range tests {
+		if got := teiTextparts(test.urns, test.texts, test.levels); got != test.want {
+			t.Errorf("teiTextparts(%q) =\n%s\nwant\n%s", test.urns, got, test.want)
+		}
+	}
+}
+
+func TestTeiRefsDecl(t *testing.T) {
+	tests := []struct {
+		levels []string
+		want   string
+	}{
+		{nil, `<refsDecl n="CTS"></refsDecl>`},
+		{[]string{"line"}, `<refsDecl n="CTS">` +
+			`<cRefPattern n="line" matchPattern="(\w+)" replacementPattern="#xpath(/tei:TEI/tei:text/tei:body/tei:div/tei:div[@n='$1'])"/>` +
+			`</refsDecl>`},
+		{[]string{"book", "line"}, `<refsDecl n="CTS">` +
+			`<cRefPattern n="line" matchPattern="(\w+)\.(\w+)" replacementPattern="#xpath(/tei:TEI/tei:text/tei:body/tei:div/tei:div[@n='$1']/tei:div[@n='$2'])"/>` +
+			`<cRefPattern n="book" matchPattern="(\w+)" replacementPattern="#xpath(/tei:TEI/tei:text/tei:body/tei:div/tei:div[@n='$1'])"/>` +
+			`</refsDecl>`},
+	}
+	for _, test := range tests {
+		if got := teiRefsDecl(test.levels); got != test.want {
+			t.Errorf("teiRefsDecl(%q) =\n%s\nwant\n%s", test.levels, got, test.want)
+		}
+	}
+}
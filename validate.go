@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type DepthMismatch struct {
+	URN            string `json:"urn"`
+	CitationScheme string `json:"citationScheme"`
+	Declared       int    `json:"declared"`
+	Found          []int  `json:"found"`
+	Nodes          int    `json:"nodes"`
+}
+
+type ValidationResponse struct {
+	Status           string          `json:"status"`
+	Service          string          `json:"service"`
+	Message          string          `json:"message,omitempty"`
+	UncataloguedData []string        `json:"uncataloguedData"`
+	MissingData      []string        `json:"missingData"`
+	DepthMismatches  []DepthMismatch `json:"depthMismatches"`
+	InvalidValues    []InvalidValue  `json:"invalidValues,omitempty"`
+	MalformedRows    []string        `json:"malformedRows,omitempty"`
+}
+
+type InvalidValue struct {
+	Object   string `json:"object"`
+	Property string `json:"property"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Message  string `json:"message"`
+}
+
+type CollectionValidationResponse struct {
+	Status        string         `json:"status"`
+	Service       string         `json:"service"`
+	Message       string         `json:"message,omitempty"`
+	InvalidValues []InvalidValue `json:"invalidValues"`
+}
+
+// ValidateCorpus cross-checks #!ctsdata against #!ctscatalog: versions with
+// data but no catalog entry, catalog entries without data, and nodes whose
+// citation depth differs from the declared citation scheme.
+func ValidateCorpus(work Work, catalog []CatalogEntry) ValidationResponse {
+	result := ValidationResponse{UncataloguedData: []string{}, MissingData: []string{}, DepthMismatches: []DepthMismatch{}}
+	var versions []string
+	depths := map[string]map[int]int{}
+	for i := range work.URN {
+		stem := ctsStem(work.URN[i])
+		if depths[stem] == nil {
+			versions = append(versions, stem)
+			depths[stem] = map[int]int{}
+		}
+		depths[stem][referenceDepth(work.URN[i])]++
+	}
+	for _, version := range versions {
+		entry, ok := catalogEntryFor(catalog, version)
+		if !ok {
+			result.UncataloguedData = append(result.UncataloguedData, version+":")
+			continue
+		}
+		declared := len(citationLevels(entry.CitationScheme))
+		mismatch := DepthMismatch{URN: entry.URN, CitationScheme: entry.CitationScheme, Declared: declared}
+		for depth, count := range depths[version] {
+			mismatch.Found = append(mismatch.Found, depth)
+			if depth != declared {
+				mismatch.Nodes += count
+			}
+		}
+		if mismatch.Nodes > 0 {
+			sort.Ints(mismatch.Found)
+			result.DepthMismatches = append(result.DepthMismatches, mismatch)
+		}
+	}
+	for _, entry := range catalog {
+		if depths[ctsStem(entry.URN)] == nil {
+			result.MissingData = append(result.MissingData, entry.URN)
+		}
+	}
+	result.Status = "Success"
+	if len(result.UncataloguedData)+len(result.MissingData)+len(result.DepthMismatches) > 0 {
+		result.Message = "Catalog and data are inconsistent."
+	}
+	return result
+}
+
+// validateText validates the text data of a CEX and reports the #!ctsdata
+// rows that could not be read.
+func validateText(str string) ValidationResponse {
+	work, err := parseWork(str)
+	result := ValidateCorpus(work, parseCatalog(str))
+	if malformed, ok := err.(MalformedRowsError); ok {
+		result.MalformedRows = malformed.Rows
+		result.Message = "Some #!ctsdata rows are malformed."
+	}
+	return result
+}
+
+// valueProblem checks a citedata value against the type and vocabulary of its
+// property and describes what is wrong with it, or returns "". Empty values
+// are not checked.
+func valueProblem(property CiteProperty, raw string) string {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return ""
+	}
+	switch property.Type {
+	case "Number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return value + " is not a number."
+		}
+	case "Boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return value + " is not a boolean."
+		}
+	case "ControlledVocabulary":
+		if len(property.Vocabulary) > 0 && !contains(property.Vocabulary, value) {
+			return value + " is not one of " + strings.Join(property.Vocabulary, ", ") + "."
+		}
+	case "CtsUrn":
+		if !isCTSURN(value) {
+			return value + " is not a valid CTS URN."
+		}
+	case "Cite2Urn":
+		if _, err := ParseCite2Urn(value); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// validationSource resolves the -validate argument: a URL or the name of a
+// CEX file under cex_source.
+func validationSource(name string) string {
+	confvar := LoadConfiguration("config.json")
+	switch {
+	case strings.HasPrefix(name, "http://"), strings.HasPrefix(name, "https://"):
+		return name
+	default:
+		return confvar.Source + strings.TrimSuffix(name, ".cex") + ".cex"
+	}
+}
+
+// ValidateSource prints the validation report of a CEX source and reports
+// whether catalog and data are consistent and all citedata values are valid.
+func ValidateSource(sourcetext string) bool {
+	data, err := getContent(sourcetext)
+	if err != nil {
+		fmt.Println(err.Error())
+		return false
+	}
+	str := string(data)
+	result := validateText(str)
+	if !strings.Contains(str, "#!ctscatalog") {
+		result.Message = "No #!ctscatalog in " + sourcetext + "."
+	}
+	result.InvalidValues = parseCollections(str).InvalidValues
+	if len(result.InvalidValues) > 0 && result.Message == "" {
+		result.Message = "Some citedata values do not match their declared property types."
+	}
+	result.Service = "validate"
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(resultJSON))
+	return result.Message == ""
+}
+
+func ReturnValidation(w http.ResponseWriter, r *http.Request) {
+	var result ValidationResponse
+	data, err := getContent(requestSource(r))
+	switch {
+	case err != nil:
+		result = ValidationResponse{Status: "Exception", Message: "Couldn't open connection."}
+	default:
+		result = validateText(string(data))
+	}
+	result.Service = "/textcatalog/validate"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
+
+func ReturnCollectionValidation(w http.ResponseWriter, r *http.Request) {
+	var result CollectionValidationResponse
+	data, err := getContent(requestSource(r))
+	switch {
+	case err != nil:
+		result = CollectionValidationResponse{Status: "Exception", Message: "Couldn't open connection."}
+	default:
+		result = CollectionValidationResponse{Status: "Success", InvalidValues: []InvalidValue{}}
+		result.InvalidValues = append(result.InvalidValues, parseCollections(string(data)).InvalidValues...)
+		if len(result.InvalidValues) > 0 {
+			result.Message = "Some citedata values do not match their declared property types."
+		}
+	}
+	result.Service = "/collections/validate"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const validateCEX = `#!ctscatalog
+urn#citationScheme#groupName#workTitle#versionLabel#exemplarLabel#online#lang
+urn:cts:ns:tg.wk.ed:#book,line#Group#Work#edition##true#grc
+urn:cts:ns:tg.wk2.ed:#line#Group#Work 2#edition##true#grc
+
+#!ctsdata
+urn:cts:ns:tg.wk.ed:1.1#one
+urn:cts:ns:tg.wk.ed:1.2#two#three
+urn:cts:ns:tg.wk.ed:1#three
+urn:cts:ns:tg.other.ed:1#four
+urn:cts:ns:tg.wk.ed:1.3
+`
+
+func TestValidateText(t *testing.T) {
+	result := validateText(validateCEX)
+	if want := []string{"urn:cts:ns:tg.wk.ed:1.2#two#three", "urn:cts:ns:tg.wk.ed:1.3"}; !reflect.DeepEqual(result.MalformedRows, want) {
+		t.Errorf("MalformedRows = %q; want %q", result.MalformedRows, want)
+	}
+	if want := []string{"urn:cts:ns:tg.other.ed:"}; !reflect.DeepEqual(result.UncataloguedData, want) {
+		t.Errorf("UncataloguedData = %q; want %q", result.UncataloguedData, want)
+	}
+	if want := []string{"urn:cts:ns:tg.wk2.ed:"}; !reflect.DeepEqual(result.MissingData, want) {
+		t.Errorf("MissingData = %q; want %q", result.MissingData, want)
+	}
+	if len(result.DepthMismatches) != 1 || result.DepthMismatches[0].Nodes != 1 || !reflect.DeepEqual(result.DepthMismatches[0].Found, []int{1, 2}) {
+		t.Errorf("DepthMismatches = %+v; want one node of depth 1 in tg.wk.ed", result.DepthMismatches)
+	}
+	if result.Message == "" {
+		t.Errorf("validateText reported no problem")
+	}
+}
+
+func TestValidateCorpus(t *testing.T) {
+	catalog := []CatalogEntry{
+		{URN: "urn:cts:ns:tg.wk.ed:", CitationScheme: "book,line"},
+		{URN: "urn:cts:ns:tg.wk.tr:", CitationScheme: "line"},
+	}
+	tests := []struct {
+		urns         []string
+		uncatalogued []string
+		missing      []string
+		mismatches   int
+	}{
+		{[]string{"urn:cts:ns:tg.wk.ed:1.1", "urn:cts:ns:tg.wk.tr:1"}, []string{}, []string{}, 0},
+		{[]string{"urn:cts:ns:tg.wk.ed:1.1"}, []string{}, []string{"urn:cts:ns:tg.wk.tr:"}, 0},
+		{[]string{"urn:cts:ns:tg.wk.ed:1.1", "urn:cts:ns:tg.wk.tr:1", "urn:cts:ns:tg.wk2.ed:1"}, []string{"urn:cts:ns:tg.wk2.ed:"}, []string{}, 0},
+		{[]string{"urn:cts:ns:tg.wk.ed:1.1", "urn:cts:ns:tg.wk.tr:1.1"}, []string{}, []string{}, 1},
+		{nil, []string{}, []string{"urn:cts:ns:tg.wk.ed:", "urn:cts:ns:tg.wk.tr:"}, 0},
+	}
+	for _, test := range tests {
+		result := ValidateCorpus(Work{URN: test.urns, Text: make([]string, len(test.urns))}, catalog)
+		if !reflect.DeepEqual(result.UncataloguedData, test.uncatalogued) || !reflect.DeepEqual(result.MissingData, test.missing) || len(result.DepthMismatches) != test.mismatches {
+			t.Errorf("ValidateCorpus(%q) = %+v", test.urns, result)
+		}
+		if consistent := test.mismatches == 0 && len(test.uncatalogued)+len(test.missing) == 0; consistent != (result.Message == "") {
+			t.Errorf("ValidateCorpus(%q) message = %q", test.urns, result.Message)
+		}
+	}
+}
+
+func TestValueProblem(t *testing.T) {
+	vocabulary := CiteProperty{Type: "ControlledVocabulary", Vocabulary: []string{"recto", "verso"}}
+	tests := []struct {
+		property CiteProperty
+		value    string
+		ok       bool
+	}{
+		{CiteProperty{Type: "Number"}, "12.5", true},
+		{CiteProperty{Type: "Number"}, " 3 ", true},
+		{CiteProperty{Type: "Number"}, "twelve", false},
+		{CiteProperty{Type: "Number"}, "", true},
+		{CiteProperty{Type: "Boolean"}, "true", true},
+		{CiteProperty{Type: "Boolean"}, "yes", false},
+		{vocabulary, "recto", true},
+		{vocabulary, "middle", false},
+		{CiteProperty{Type: "ControlledVocabulary"}, "anything", true},
+		{CiteProperty{Type: "CtsUrn"}, "urn:cts:ns:tg.wk.ed:1.1", true},
+		{CiteProperty{Type: "CtsUrn"}, "urn:cite2:hmt:msA.v1:12r", false},
+		{CiteProperty{Type: "Cite2Urn"}, "urn:cite2:hmt:msA.v1:12r", true},
+		{CiteProperty{Type: "Cite2Urn"}, "urn:cite2:hmt:msA.v1", false},
+		{CiteProperty{Type: "String"}, "anything", true},
+	}
+	for _, test := range tests {
+		if got := valueProblem(test.property, test.value); (got == "") != test.ok {
+			t.Errorf("valueProblem(%s, %q) = %q", test.property.Type, test.value, got)
+		}
+	}
+}
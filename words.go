@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type WordCount struct {
+	Word      string  `json:"word"`
+	Count     int     `json:"count"`
+	Frequency float64 `json:"frequency"`
+}
+
+type WordsResponse struct {
+	RequestUrn []string    `json:"requestUrn"`
+	Status     string      `json:"status"`
+	Service    string      `json:"service"`
+	Message    string      `json:"message,omitempty"`
+	Tokens     int         `json:"tokens"`
+	Distinct   int         `json:"distinct"`
+	Offset     int         `json:"offset"`
+	Words      []WordCount `json:"words"`
+}
+
+// WordFrequencies counts the normalized word forms of the given nodes.
+// Frequencies are relative to the total number of word tokens.
+func WordFrequencies(texts []string) ([]WordCount, int) {
+	counts := map[string]int{}
+	total := 0
+	for i := range texts {
+		for _, token := range tokenize(texts[i]) {
+			if isWordToken(token) {
+				counts[normalizeToken(token)]++
+				total++
+			}
+		}
+	}
+	var result []WordCount
+	for word, count := range counts {
+		result = append(result, WordCount{Word: word, Count: count, Frequency: float64(count) / float64(total)})
+	}
+	return result, total
+}
+
+// sortWords orders by "count" (default, descending) or "word" (ascending);
+// order "asc" or "desc" overrides the direction.
+func sortWords(words []WordCount, by string, order string) {
+	descending := by != "word"
+	switch order {
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	}
+	sort.Slice(words, func(i, j int) bool {
+		a, b := words[i], words[j]
+		if descending {
+			a, b = b, a
+		}
+		if by != "word" && a.Count != b.Count {
+			return a.Count < b.Count
+		}
+		if by != "word" {
+			return words[i].Word < words[j].Word
+		}
+		return a.Word < b.Word
+	})
+}
+
+// pageWords returns at most limit words from offset on, together with the
+// offset actually used. An offset outside the list yields an empty page and a
+// negative limit returns every remaining word.
+func pageWords(words []WordCount, offset int, limit int) ([]WordCount, int) {
+	if offset < 0 || offset > len(words) {
+		offset = len(words)
+	}
+	if limit < 0 || limit > len(words)-offset {
+		limit = len(words) - offset
+	}
+	return words[offset : offset+limit], offset
+}
+
+func ReturnWords(w http.ResponseWriter, r *http.Request) {
+	sourcetext := requestSource(r)
+	requestUrn := mux.Vars(r)["URN"]
+	query := r.URL.Query()
+	var result WordsResponse
+	switch {
+	case isCTSURN(requestUrn) != true:
+		message := requestUrn + " is not valid CTS."
+		result = WordsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+	default:
+		workResult := ParseWork(CTSParams{Sourcetext: sourcetext})
+		var texts []string
+		for _, i := range explicitScopeIndices(workResult, requestUrn) {
+			texts = append(texts, workResult.Text[i])
+		}
+		switch {
+		case len(texts) == 0:
+			message := "No results for " + requestUrn
+			result = WordsResponse{RequestUrn: []string{requestUrn}, Status: "Exception", Message: message}
+		default:
+			words, total := WordFrequencies(texts)
+			sortWords(words, query.Get("sort"), query.Get("order"))
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			limit, err := strconv.Atoi(query.Get("limit"))
+			if err != nil {
+				limit = -1
+			}
+			page, offset := pageWords(words, offset, limit)
+			result = WordsResponse{RequestUrn: []string{requestUrn},
+				Status:   "Success",
+				Tokens:   total,
+				Distinct: len(words),
+				Offset:   offset,
+				Words:    page}
+		}
+	}
+	result.Service = "/texts/words"
+	resultJSON, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintln(w, string(resultJSON))
+}
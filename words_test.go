@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPageWords(t *testing.T) {
+	words := []WordCount{{Word: "a"}, {Word: "b"}, {Word: "c"}}
+	tests := []struct {
+		offset, limit int
+		want          string
+		wantOffset    int
+	}{
+		{0, -1, "abc", 0},
+		{0, 2, "ab", 0},
+		{1, 1, "b", 1},
+		{1, 10, "bc", 1},
+		{3, 1, "", 3},
+		{5, 1, "", 3},
+		{-1, 1, "", 3},
+		{1, math.MaxInt, "bc", 1},
+		{math.MaxInt, math.MaxInt, "", 3},
+	}
+	for _, test := range tests {
+		page, offset := pageWords(words, test.offset, test.limit)
+		got := ""
+		for _, word := range page {
+			got += word.Word
+		}
+		if got != test.want || offset != test.wantOffset {
+			t.Errorf("pageWords(%d, %d) = %q, %d; want %q, %d", test.offset, test.limit, got, offset, test.want, test.wantOffset)
+		}
+	}
+}
+
+func TestSortWords(t *testing.T) {
+	tests := []struct {
+		by, order string
+		want      string
+	}{
+		{"", "", "bca"},
+		{"count", "asc", "abc"},
+		{"word", "", "abc"},
+		{"word", "desc", "cba"},
+	}
+	for _, test := range tests {
+		words := []WordCount{{Word: "a", Count: 1}, {Word: "b", Count: 3}, {Word: "c", Count: 3}}
+		sortWords(words, test.by, test.order)
+		got := ""
+		for _, word := range words {
+			got += word.Word
+		}
+		if got != test.want {
+			t.Errorf("sortWords(%q, %q) = %q; want %q", test.by, test.order, got, test.want)
+		}
+	}
+}